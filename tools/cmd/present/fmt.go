@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+
+	"github.com/Tobecoder/go/tools/present"
+)
+
+// runFmt implements the fmt command, which rewrites present files in
+// canonical form. Like gofmt, it prints the result to standard output
+// unless -w, -l or -d is given. Markdown documents are only checked, as
+// the printer writes present markup. It returns the exit status: 0 on
+// success and 2 if a file couldn't be read, parsed or written.
+func runFmt(args []string) int {
+	fs := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := fs.Bool("w", false, "write result to (source) file instead of stdout")
+	list := fs.Bool("l", false, "list files whose formatting differs from present fmt's")
+	diff := fs.Bool("d", false, "display diffs instead of rewriting files")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: present fmt [flags] [path ...]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	f := &formatter{write: *write, list: *list, diff: *diff}
+	if fs.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "present: cannot use -w with standard input")
+			return 2
+		}
+		f.file("<standard input>", os.Stdin)
+		return f.status
+	}
+	files, err := presentFiles(fs.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "present:", err)
+		return 2
+	}
+	for _, name := range files {
+		r, err := os.Open(name)
+		if err != nil {
+			f.report(err)
+			continue
+		}
+		f.file(name, r)
+		r.Close()
+	}
+	return f.status
+}
+
+type formatter struct {
+	write, list, diff bool
+	status            int
+}
+
+func (f *formatter) report(err error) {
+	var list present.ErrorList
+	if errors.As(err, &list) {
+		for _, e := range list {
+			fmt.Fprintln(os.Stderr, e)
+		}
+	} else {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	f.status = 2
+}
+
+// file formats the present file read from r.
+func (f *formatter) file(name string, r io.Reader) {
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		f.report(err)
+		return
+	}
+	ctx := &present.Context{ReadFile: ioutil.ReadFile}
+	doc, err := ctx.Parse(bytes.NewReader(src), name, 0)
+	if err != nil {
+		// Printing a partially parsed document would lose the
+		// lines that couldn't be parsed.
+		f.report(err)
+		return
+	}
+	res := src
+	if !present.IsMarkdown(name) {
+		// Print writes present markup, which would convert a Markdown
+		// document, so those are left as they are.
+		var buf bytes.Buffer
+		if err := present.Print(&buf, doc); err != nil {
+			f.report(fmt.Errorf("%s: %v", name, err))
+			return
+		}
+		res = buf.Bytes()
+	}
+
+	if !bytes.Equal(src, res) {
+		if f.list {
+			fmt.Println(name)
+		}
+		if f.write {
+			fi, err := os.Stat(name)
+			if err != nil {
+				f.report(err)
+				return
+			}
+			if err := ioutil.WriteFile(name, res, fi.Mode().Perm()); err != nil {
+				f.report(err)
+				return
+			}
+		}
+		if f.diff {
+			d, err := diffBytes(name, src, res)
+			if err != nil {
+				f.report(fmt.Errorf("computing diff: %v", err))
+				return
+			}
+			os.Stdout.Write(d)
+		}
+	}
+	if !f.list && !f.write && !f.diff {
+		os.Stdout.Write(res)
+	}
+}
+
+// diffBytes returns a unified diff of b1 and b2 made by the diff program.
+func diffBytes(name string, b1, b2 []byte) ([]byte, error) {
+	f1, err := writeTemp(b1)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f1)
+	f2, err := writeTemp(b2)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f2)
+
+	data, err := exec.Command("diff", "-u", "-L", name+".orig", "-L", name, f1, f2).CombinedOutput()
+	if len(data) > 0 {
+		// diff exits with a non-zero status when the files don't match.
+		// Ignore that failure as long as we get output.
+		err = nil
+	}
+	return data, err
+}
+
+func writeTemp(data []byte) (string, error) {
+	f, err := ioutil.TempFile("", "present-fmt")
+	if err != nil {
+		return "", err
+	}
+	_, err = f.Write(data)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Tobecoder/go/tools/present"
+)
+
+// runHelp implements the help command. With the argument "commands" it
+// prints a reference of the commands that may appear in present files.
+func runHelp(args []string) int {
+	switch {
+	case len(args) == 0:
+		fmt.Fprint(os.Stdout, usage)
+		return 0
+	case len(args) == 1 && args[0] == "commands":
+		printCommands()
+		return 0
+	}
+	fmt.Fprintln(os.Stderr, "usage: present help [commands]")
+	return 2
+}
+
+// printCommands prints the usage, description, flags and arguments of
+// each registered command.
+func printCommands() {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	for i, cmd := range present.Commands() {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w, cmd.Usage())
+		if cmd.Doc != "" {
+			fmt.Fprintf(w, "\t%s\n", cmd.Doc)
+		}
+		if cmd.Block {
+			fmt.Fprintln(w, "\tTakes the indented block below it as its body.")
+		}
+		if len(cmd.Flags)+len(cmd.Args) > 0 {
+			fmt.Fprintln(w)
+		}
+		for _, f := range cmd.Flags {
+			fmt.Fprintf(w, "\t-%s\t%s\n", f.Name, f.Doc)
+		}
+		for _, a := range cmd.Args {
+			fmt.Fprintf(w, "\t%s\t%s\n", a.Name, argDoc(a))
+		}
+	}
+	w.Flush()
+}
+
+// argDoc describes the argument a, with the values it takes.
+func argDoc(a present.Arg) string {
+	var notes []string
+	if a.Optional {
+		notes = append(notes, "optional")
+	}
+	if h := a.Type.Hint(); h != "" && len(a.Choices) == 0 {
+		notes = append(notes, h)
+	}
+	if len(notes) == 0 {
+		return a.Doc
+	}
+	return strings.TrimSpace(a.Doc + " (" + strings.Join(notes, ", ") + ")")
+}
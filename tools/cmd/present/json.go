@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Tobecoder/go/tools/present"
+)
+
+// runJSON implements the json command, which prints the structure of a
+// present file as JSON for tools that want the document rather than HTML.
+func runJSON(args []string) int {
+	fs := flag.NewFlagSet("json", flag.ExitOnError)
+	indent := fs.Bool("indent", false, "indent the output")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: present json [-indent] file")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	file := fs.Arg(0)
+	f, err := os.Open(file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "present:", err)
+		return 1
+	}
+	defer f.Close()
+	doc, err := present.Parse(f, file, 0)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	enc := json.NewEncoder(os.Stdout)
+	if *indent {
+		enc.SetIndent("", "\t")
+	}
+	if err := enc.Encode(doc); err != nil {
+		fmt.Fprintln(os.Stderr, "present:", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/Tobecoder/go/tools/present"
+)
+
+// runLint implements the lint command. It prints one
+// "file:line:column: message" line per problem, without the column when
+// the whole line is at fault, and returns the exit status: 0 if no
+// problems were found, 1 if some were, and 2 if the files couldn't be read.
+func runLint(args []string) int {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	maxCode := fs.Int("max-code-lines", 30, "report code blocks longer than this many lines (0 for no limit)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: present lint [flags] [path ...]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	files, err := presentFiles(paths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "present:", err)
+		return 2
+	}
+
+	status := 0
+	ctx := &present.Context{ReadFile: ioutil.ReadFile}
+	opt := present.LintOptions{MaxCodeLines: *maxCode}
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "present:", err)
+			status = 2
+			continue
+		}
+		errs := ctx.Lint(f, file, opt)
+		f.Close()
+		for _, e := range errs {
+			fmt.Println(e)
+		}
+		if len(errs) > 0 && status == 0 {
+			status = 1
+		}
+	}
+	return status
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// capture returns what f writes to standard output, and its result.
+func capture(t *testing.T, f func() int) (string, int) {
+	out, err := ioutil.TempFile(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	stdout := os.Stdout
+	os.Stdout = out
+	status := f()
+	os.Stdout = stdout
+	b, err := ioutil.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b), status
+}
+
+func TestLint(t *testing.T) {
+	dir := t.TempDir()
+	for name, src := range map[string]string{
+		"good.slide":       "Title\n\n* Slide\n\nText.\n",
+		"bad.slide":        "Title\nOrder: first\n\n* Empty\n\n* Slide\n\n.image gopher.png\n",
+		"sub/long.article": "Title\n\n* Code\n\n.code prog.go\n",
+		"sub/prog.go":      "package main\n\nfunc main() {\n}\n",
+		"md/notes.md":      "# Notes\n\n## Empty\n\n## Text\n\n// not a comment\n",
+		"notes.txt":        "not a present file\n",
+	} {
+		name = filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(name, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		args   []string
+		out    string
+		status int
+	}{
+		{[]string{"good.slide"}, "", 0},
+		{
+			[]string{"bad.slide"},
+			"bad.slide:2:8: bad order \"Order: first\": must be a positive number\n" +
+				"bad.slide:4: section \"Empty\" is empty\n" +
+				"bad.slide:8:8: image gopher.png not found\n",
+			1,
+		},
+		{[]string{"-max-code-lines", "3", "sub"}, "sub/long.article:5: code block has 4 lines; more than 3\n", 1},
+		{[]string{"md"}, "md/notes.md:3: section \"Empty\" is empty\n", 1},
+		{[]string{"-max-code-lines", "0", "sub", "good.slide"}, "", 0},
+		{[]string{"missing.slide"}, "", 2},
+	} {
+		args := append([]string(nil), tt.args...)
+		for i, a := range args {
+			if strings.Contains(a, ".") || a == "sub" || a == "md" {
+				args[i] = filepath.Join(dir, a)
+			}
+		}
+		out, status := capture(t, func() int { return runLint(args) })
+		out = strings.ReplaceAll(out, dir+string(filepath.Separator), "")
+		if out != tt.out || status != tt.status {
+			t.Errorf("present lint %s: got status %d and\n%s\nwant status %d and\n%s", strings.Join(tt.args, " "), status, out, tt.status, tt.out)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+// Present works with the article and slide files of package present.
+//
+// Usage:
+//
+//	present lint [flags] [path ...]
+//	present fmt [-w] [-l] [-d] [path ...]
+//	present help [commands]
+//	present json [-indent] file
+//	present render [-theme name] [-layout slides|article] [-o file] [-record [-timeout d]] file
+//	present serve [-http addr] [-theme name] [-drafts] [dir]
+//
+// Paths may be files or directories; directories are searched for .article
+// and .slide files.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const usage = `usage: present command [arguments]
+
+Commands:
+  fmt    format present files
+  help   show this help, or with "commands" the commands present files may use
+  json   print the structure of a present file as JSON
+  lint   report problems in present files
+  render render a present file as a standalone HTML page
+  serve  browse and serve the present files in a directory
+`
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	args := flag.Args()[1:]
+	switch flag.Arg(0) {
+	case "fmt":
+		os.Exit(runFmt(args))
+	case "help":
+		os.Exit(runHelp(args))
+	case "json":
+		os.Exit(runJSON(args))
+	case "lint":
+		os.Exit(runLint(args))
+	case "render":
+		os.Exit(runRender(args))
+	case "serve":
+		os.Exit(runServe(args))
+	default:
+		fmt.Fprintf(os.Stderr, "present: unknown command %q\n", flag.Arg(0))
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// isPresentFile reports whether name has the extension of a present file,
+// in present markup or in Markdown.
+func isPresentFile(name string) bool {
+	ext := filepath.Ext(name)
+	return ext == ".article" || ext == ".slide" || ext == ".md"
+}
+
+// presentFiles expands paths into the present files they name. Files are
+// taken as given; directories are walked for present files.
+func presentFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, p := range paths {
+		fi, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		err = filepath.Walk(p, func(path string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if fi.IsDir() && path != p && strings.HasPrefix(fi.Name(), ".") {
+				return filepath.SkipDir
+			}
+			if !fi.IsDir() && isPresentFile(path) {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
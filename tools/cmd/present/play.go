@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"go/format"
+	"net/http"
+
+	"github.com/Go-zh/tools/godoc/static"
+	"golang.org/x/tools/imports"
+)
+
+// The .play snippets of served pages use the tour's transport: programs
+// run over the playground socket, with the client side in playground.js,
+// and are formatted by posting them to /fmt.
+
+// playgroundJS returns playground.js, which defines SocketTransport and
+// PlaygroundOutput.
+func playgroundJS() []byte {
+	return []byte(static.Files["playground.js"])
+}
+
+type fmtResponse struct {
+	Body  string
+	Error string
+}
+
+// fmtHandler formats the program in the body parameter, fixing its
+// imports too if the imports parameter is "true".
+func fmtHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		b   []byte
+		err error
+	)
+	body := []byte(r.FormValue("body"))
+	if r.FormValue("imports") == "true" {
+		b, err = imports.Process("prog.go", body, nil)
+	} else {
+		b, err = format.Source(body)
+	}
+	resp := new(fmtResponse)
+	if err != nil {
+		resp.Error = err.Error()
+	} else {
+		resp.Body = string(b)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/Tobecoder/go/tools/present"
+)
+
+// With -record, render builds and runs the .play snippets of a document
+// and embeds their output in the page, so that exported pages show what
+// the programs print without a server to run them. The outputs of the
+// programs that succeed are cached by the hash of the program, so each
+// of them is run only once; failures, which may come from the timeout or
+// the Go installation, are tried again next time.
+
+// recorder runs programs and caches their output.
+type recorder struct {
+	timeout time.Duration
+	cache   string                                     // directory of cached outputs; "" to not cache
+	run     func(prog []byte) (*present.Output, error) // builds and runs a program
+}
+
+// newRecorder returns a recorder that stops programs after timeout and
+// caches their output in the user's cache directory, if there is one.
+func newRecorder(timeout time.Duration) *recorder {
+	r := &recorder{timeout: timeout}
+	r.run = r.runProgram
+	if dir, err := os.UserCacheDir(); err == nil {
+		r.cache = filepath.Join(dir, "present", "record")
+	}
+	return r
+}
+
+// record sets the Output of the .play snippets in doc.
+func (r *recorder) record(doc *present.Doc) error {
+	var walk func(elems []present.Elem) error
+	walk = func(elems []present.Elem) error {
+		for i, e := range elems {
+			switch e := e.(type) {
+			case present.Code:
+				if e.Prog == nil {
+					continue
+				}
+				out, err := r.output(e.Prog)
+				if err != nil {
+					return fmt.Errorf("%s: %v", e.Cmd, err)
+				}
+				e.Output = out
+				elems[i] = e
+			case present.Section:
+				if err := walk(e.Elem); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	for _, s := range doc.Sections {
+		if err := walk(s.Elem); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// output returns the output of prog, from the cache if it is there.
+// Only the outputs of programs that succeeded are cached.
+func (r *recorder) output(prog []byte) (*present.Output, error) {
+	sum := sha256.Sum256(prog)
+	var cached string
+	if r.cache != "" {
+		cached = filepath.Join(r.cache, hex.EncodeToString(sum[:])+".json")
+		if b, err := ioutil.ReadFile(cached); err == nil {
+			out := new(present.Output)
+			if json.Unmarshal(b, out) == nil {
+				return out, nil
+			}
+		}
+	}
+	out, err := r.run(prog)
+	if err != nil {
+		return nil, err
+	}
+	if cached != "" && out.Error == "" {
+		if b, err := json.Marshal(out); err == nil && os.MkdirAll(r.cache, 0755) == nil {
+			ioutil.WriteFile(cached, b, 0644)
+		}
+	}
+	return out, nil
+}
+
+// runProgram builds and runs prog, each step stopped after the timeout.
+// Failures of the program are reported in the Output; the error is for
+// failures to run it at all.
+func (r *recorder) runProgram(prog []byte) (*present.Output, error) {
+	dir, err := ioutil.TempDir("", "present-record")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "prog.go")
+	bin := filepath.Join(dir, "prog")
+	if runtime.GOOS == "windows" {
+		bin += ".exe"
+	}
+	if err := ioutil.WriteFile(src, prog, 0644); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+	build := exec.CommandContext(ctx, "go", "build", "-o", bin, src)
+	build.Dir = dir
+	if b, err := build.CombinedOutput(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return &present.Output{Error: fmt.Sprintf("build timed out after %v", r.timeout)}, nil
+		}
+		if _, ok := err.(*exec.ExitError); !ok {
+			return nil, err
+		}
+		// Report the errors against prog.go, not the temporary directory.
+		text := strings.Replace(string(b), dir+string(filepath.Separator), "", -1)
+		return &present.Output{Text: text, Error: "build failed"}, nil
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+	var buf bytes.Buffer
+	cmd := exec.CommandContext(ctx, bin)
+	cmd.Dir = dir
+	cmd.Stdout = &buf
+	cmd.Stderr = &buf
+	err = cmd.Run()
+	out := &present.Output{Text: buf.String()}
+	var exit *exec.ExitError
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		out.Error = fmt.Sprintf("timed out after %v", r.timeout)
+	case errors.As(err, &exit):
+		out.Error = exit.Error()
+	case err != nil:
+		return nil, err
+	}
+	return out, nil
+}
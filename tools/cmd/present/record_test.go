@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Tobecoder/go/tools/present"
+)
+
+// fakeRecorder returns a recorder caching in a temporary directory whose
+// programs print themselves, fail if they contain "fail" and can't be
+// run if they contain "broken". It counts the runs in *runs.
+func fakeRecorder(t *testing.T, runs *int) *recorder {
+	return &recorder{
+		timeout: time.Second,
+		cache:   t.TempDir(),
+		run: func(prog []byte) (*present.Output, error) {
+			*runs++
+			switch {
+			case strings.Contains(string(prog), "broken"):
+				return nil, errors.New("cannot run")
+			case strings.Contains(string(prog), "fail"):
+				return &present.Output{Text: string(prog), Error: "timed out after 1s"}, nil
+			}
+			return &present.Output{Text: string(prog)}, nil
+		},
+	}
+}
+
+func TestRecorderCache(t *testing.T) {
+	var runs int
+	r := fakeRecorder(t, &runs)
+	for i := 0; i < 2; i++ {
+		out, err := r.output([]byte("hello"))
+		if err != nil || out.Text != "hello" || out.Error != "" {
+			t.Fatalf("run %d: got %+v, %v", i, out, err)
+		}
+	}
+	if runs != 1 {
+		t.Errorf("got %d runs of the same program, want 1", runs)
+	}
+
+	// The cache outlives the recorder.
+	again := fakeRecorder(t, &runs)
+	again.cache = r.cache
+	if out, err := again.output([]byte("hello")); err != nil || out.Text != "hello" || runs != 1 {
+		t.Errorf("new recorder: got %+v, %v after %d runs, want the cached output", out, err, runs)
+	}
+	if _, err := again.output([]byte("other")); err != nil || runs != 2 {
+		t.Errorf("other program: got %v after %d runs, want 2 runs", err, runs)
+	}
+}
+
+func TestRecorderFailures(t *testing.T) {
+	var runs int
+	r := fakeRecorder(t, &runs)
+	for i := 0; i < 2; i++ {
+		out, err := r.output([]byte("fail"))
+		if err != nil || out.Error == "" {
+			t.Fatalf("run %d: got %+v, %v; want a failed run", i, out, err)
+		}
+	}
+	if runs != 2 {
+		t.Errorf("got %d runs of a failing program, want 2: failures aren't cached", runs)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := r.output([]byte("broken")); err == nil {
+			t.Fatalf("run %d: got no error", i)
+		}
+	}
+	if runs != 4 {
+		t.Errorf("got %d runs, want 4: errors aren't cached", runs)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	var runs int
+	r := fakeRecorder(t, &runs)
+	doc := &present.Doc{Sections: []present.Section{{
+		Elem: []present.Elem{
+			present.Code{Cmd: ".code prog.go"},
+			present.Code{Cmd: ".play one.go", Prog: []byte("one")},
+			present.Section{Elem: []present.Elem{
+				present.Code{Cmd: ".play two.go", Prog: []byte("two")},
+			}},
+		},
+	}}}
+	if err := r.record(doc); err != nil {
+		t.Fatal(err)
+	}
+	elems := doc.Sections[0].Elem
+	if out := elems[0].(present.Code).Output; out != nil {
+		t.Errorf(".code: got output %+v", out)
+	}
+	if out := elems[1].(present.Code).Output; out == nil || out.Text != "one" {
+		t.Errorf(".play: got output %+v", out)
+	}
+	if out := elems[2].(present.Section).Elem[0].(present.Code).Output; out == nil || out.Text != "two" {
+		t.Errorf(".play in a subsection: got output %+v", out)
+	}
+
+	doc.Sections[0].Elem = append(doc.Sections[0].Elem, present.Code{Cmd: ".play bad.go", Prog: []byte("broken")})
+	if err := r.record(doc); err == nil || !strings.HasPrefix(err.Error(), ".play bad.go: ") {
+		t.Errorf("got error %v, want one naming the command", err)
+	}
+}
+
+func TestRunProgram(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds programs")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("no go command")
+	}
+	r := &recorder{timeout: 5 * time.Second}
+	for _, tt := range []struct {
+		prog       string
+		text, fail string // wanted prefixes
+	}{
+		{"package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Println(\"hi\") }\n", "hi\n", ""},
+		{"package main\n\nfunc main() { undefined() }\n", "", "build failed"},
+		{"package main\n\nimport \"os\"\n\nfunc main() { os.Exit(3) }\n", "", "exit status 3"},
+		{"package main\n\nimport \"time\"\n\nfunc main() { time.Sleep(time.Minute) }\n", "", "timed out after 5s"},
+	} {
+		out, err := r.runProgram([]byte(tt.prog))
+		if err != nil {
+			t.Errorf("%q: %v", tt.prog, err)
+			continue
+		}
+		if !strings.HasPrefix(out.Text, tt.text) || out.Error != tt.fail {
+			t.Errorf("%q: got %+v, want text %q and error %q", tt.prog, out, tt.text, tt.fail)
+		}
+	}
+}
+
+func TestRunProgramBuildTimeout(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("no go command")
+	}
+	r := &recorder{timeout: time.Nanosecond}
+	out, err := r.runProgram([]byte("package main\n\nfunc main() {}\n"))
+	if err != nil || out.Error != "build timed out after 1ns" {
+		t.Errorf("got %+v, %v; want a build timeout", out, err)
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"embed"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Tobecoder/go/tools/present"
+)
+
+// Themes decide how rendered documents look. A theme is a directory with
+// the templates action.tmpl, slides.tmpl and article.tmpl and the CSS and
+// JavaScript files they embed; files it lacks are taken from the default
+// theme, so a theme may be as small as a theme.css setting the colours.
+// Besides the built-in themes, any such directory can be named.
+
+//go:embed themes
+var builtinThemes embed.FS
+
+const defaultTheme = "default"
+
+type theme struct {
+	name string
+	fsys fs.FS
+}
+
+// themeNames returns the names of the built-in themes.
+func themeNames() []string {
+	entries, _ := builtinThemes.ReadDir("themes")
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// findTheme returns the built-in theme with the given name or, failing
+// that, the theme in the directory name.
+func findTheme(name string) (*theme, error) {
+	if sub, err := fs.Sub(builtinThemes, "themes/"+name); err == nil && !strings.Contains(name, "/") {
+		if _, err := fs.Stat(sub, "."); err == nil {
+			return &theme{name, sub}, nil
+		}
+	}
+	if fi, err := os.Stat(name); err == nil && fi.IsDir() {
+		return &theme{name, os.DirFS(name)}, nil
+	}
+	return nil, fmt.Errorf("unknown theme %q; built-in themes are %s", name, strings.Join(themeNames(), ", "))
+}
+
+// readFile reads a file of the theme, or of the default theme if the
+// theme doesn't have it.
+func (t *theme) readFile(name string) ([]byte, error) {
+	b, err := fs.ReadFile(t.fsys, name)
+	if err != nil && t.name != defaultTheme {
+		b, err = builtinThemes.ReadFile("themes/" + defaultTheme + "/" + name)
+	}
+	return b, err
+}
+
+// template returns the theme's template for the layout, "slides" or
+// "article". Images that the document at docPath refers to by relative
+// URLs are embedded, so that the output stands alone.
+func (t *theme) template(layout, docPath string) (*template.Template, error) {
+	tmpl := present.Template().Funcs(template.FuncMap{
+		"css": func(name string) (template.CSS, error) {
+			b, err := t.readFile(name)
+			return template.CSS(b), err
+		},
+		"js": func(name string) (template.JS, error) {
+			b, err := t.readFile(name)
+			return template.JS(b), err
+		},
+		"src": func(u string) template.URL {
+			return embedURL(docPath, u)
+		},
+		"anchor": anchor,
+		"attrs": func(s present.Section) template.HTMLAttr {
+			return slideAttrs(docPath, s)
+		},
+	})
+	for _, name := range []string{"action.tmpl", layout + ".tmpl"} {
+		b, err := t.readFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("theme %s: %v", t.name, err)
+		}
+		if _, err := tmpl.New(name).Parse(string(b)); err != nil {
+			return nil, fmt.Errorf("theme %s: %v", t.name, err)
+		}
+	}
+	return tmpl, nil
+}
+
+// anchor returns the HTML id of the section with the given number.
+func anchor(number []int) string {
+	s := make([]string, len(number))
+	for i, n := range number {
+		s[i] = strconv.Itoa(n)
+	}
+	return "sec-" + strings.Join(s, "-")
+}
+
+// embedURL returns u as a data URL holding the file it refers to, if u is
+// relative to the document at docPath and the file can be read, and as
+// it is otherwise.
+func embedURL(docPath, u string) template.URL {
+	p, err := url.Parse(u)
+	if err != nil || p.Scheme != "" || p.Host != "" || path.IsAbs(p.Path) || p.Path == "" {
+		return template.URL(u)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(filepath.Dir(docPath), filepath.FromSlash(p.Path)))
+	if err != nil {
+		return template.URL(u)
+	}
+	typ := mime.TypeByExtension(path.Ext(p.Path))
+	if typ == "" {
+		typ = "application/octet-stream"
+	}
+	return template.URL("data:" + typ + ";base64," + base64.StdEncoding.EncodeToString(b))
+}
+
+// slideAttrs returns the attributes of a slide, like s.HTMLAttributes but
+// with the background images set by .background embedded.
+func slideAttrs(docPath string, s present.Section) template.HTMLAttr {
+	var attrs []string
+	if len(s.Classes) > 0 {
+		attrs = append(attrs, fmt.Sprintf("class=%q", template.HTMLEscapeString(strings.Join(s.Classes, " "))))
+	}
+	if len(s.Styles) > 0 {
+		styles := make([]string, len(s.Styles))
+		for i, style := range s.Styles {
+			const prefix, suffix = "background-image: url('", "')"
+			if strings.HasPrefix(style, prefix) && strings.HasSuffix(style, suffix) {
+				u := style[len(prefix) : len(style)-len(suffix)]
+				style = prefix + string(embedURL(docPath, u)) + suffix
+			}
+			styles[i] = style
+		}
+		attrs = append(attrs, fmt.Sprintf("style=%q", template.HTMLEscapeString(strings.Join(styles, " "))))
+	}
+	return template.HTMLAttr(strings.Join(attrs, " "))
+}
+
+// layoutOf returns the layout used for the document at docPath when none
+// is asked for: slides for .slide files and article for the others.
+func layoutOf(docPath string) string {
+	if filepath.Ext(docPath) == ".slide" {
+		return "slides"
+	}
+	return "article"
+}
+
+// renderDoc writes doc, parsed from docPath, as a standalone HTML page.
+func renderDoc(w io.Writer, doc *present.Doc, docPath, layout string, t *theme) error {
+	tmpl, err := t.template(layout, docPath)
+	if err != nil {
+		return err
+	}
+	return doc.Render(w, tmpl)
+}
+
+// runRender implements the render command.
+func runRender(args []string) int {
+	fs := flag.NewFlagSet("render", flag.ExitOnError)
+	themeName := fs.String("theme", defaultTheme, "theme: the name of a built-in theme or a theme directory")
+	layout := fs.String("layout", "", `"slides" or "article"; by default slides for .slide files and article for others`)
+	out := fs.String("o", "", "write the page to this file instead of standard output")
+	record := fs.Bool("record", false, "run the .play snippets and embed their output in the page")
+	timeout := fs.Duration("timeout", 10*time.Second, "with -record, stop programs that run longer than this")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: present render [flags] file")
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "built-in themes: %s\n", strings.Join(themeNames(), ", "))
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	file := fs.Arg(0)
+	if *layout == "" {
+		*layout = layoutOf(file)
+	}
+	if *layout != "slides" && *layout != "article" {
+		fmt.Fprintf(os.Stderr, "present: unknown layout %q\n", *layout)
+		return 2
+	}
+	t, err := findTheme(*themeName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "present:", err)
+		return 2
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "present:", err)
+		return 1
+	}
+	defer f.Close()
+	doc, err := present.Parse(f, file, 0)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if *record {
+		if err := newRecorder(*timeout).record(doc); err != nil {
+			fmt.Fprintln(os.Stderr, "present:", err)
+			return 1
+		}
+	}
+
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		o, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "present:", err)
+			return 1
+		}
+		defer o.Close()
+		w = o
+	}
+	if err := renderDoc(w, doc, file, *layout, t); err != nil {
+		fmt.Fprintln(os.Stderr, "present:", err)
+		return 1
+	}
+	return 0
+}
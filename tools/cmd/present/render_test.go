@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Tobecoder/go/tools/present"
+)
+
+const renderDeck = `Deck
+Subtitle
+
+* Intro
+
+.background bg.png
+
+Hello.
+
+.image gopher.png
+
+.image https://go.dev/images/gopher.png
+
+.iframe https://go.dev/play/ 400 300
+
+* Outro
+
+Bye.
+`
+
+// writeFiles writes the files, by name relative to dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, src := range files {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(name, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// dataURL returns the data URL embedding the PNG image b.
+func dataURL(b string) string {
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString([]byte(b))
+}
+
+func TestRenderThemes(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"deck.slide":     renderDeck,
+		"gopher.png":     "gopher",
+		"bg.png":         "background",
+		"mine/theme.css": "/* my theme */",
+	})
+	name := filepath.Join(dir, "deck.slide")
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := present.Parse(f, name, 0)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, themeName := range append(themeNames(), filepath.Join(dir, "mine")) {
+		th, err := findTheme(themeName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		css, err := th.readFile("theme.css")
+		if err != nil {
+			t.Fatal(err)
+		}
+		colours := strings.SplitN(string(css), "\n", 2)[0]
+		for _, layout := range []string{"slides", "article"} {
+			var buf bytes.Buffer
+			if err := renderDoc(&buf, doc, name, layout, th); err != nil {
+				t.Fatalf("%s %s: %v", themeName, layout, err)
+			}
+			out := buf.String()
+			want := []string{
+				"<title>Deck</title>",
+				colours,
+				`src="` + dataURL("gopher") + `"`,
+				`src="https://go.dev/images/gopher.png"`,
+				`<iframe src="https://go.dev/play/" height="300" width="400" sandbox="allow-scripts allow-same-origin" allowfullscreen>`,
+			}
+			switch layout {
+			case "slides":
+				want = append(want,
+					`class="background" style="background-image: url(&#39;`+dataURL("background")+`&#39;)"`,
+					`<span class="pagenumber">2</span>`,
+					"addEventListener('keydown'")
+			case "article":
+				want = append(want, `<nav id="toc">`, `<a href="#sec-2">Outro</a>`, `<section id="sec-2"`)
+			}
+			for _, w := range want {
+				if !strings.Contains(out, w) {
+					t.Errorf("%s %s: output lacks %q", themeName, layout, w)
+				}
+			}
+			if strings.Contains(out, `src="gopher.png"`) {
+				t.Errorf("%s %s: local image not embedded", themeName, layout)
+			}
+		}
+	}
+}
+
+func TestRunRender(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"deck.slide": renderDeck, "gopher.png": "gopher"})
+	out := filepath.Join(dir, "deck.html")
+	if status := runRender([]string{"-theme", "dark", "-layout", "article", "-o", out, filepath.Join(dir, "deck.slide")}); status != 0 {
+		t.Fatalf("present render: got status %d", status)
+	}
+	b, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "Colours of the dark theme") || !strings.Contains(string(b), `<nav id="toc">`) {
+		t.Errorf("present render -theme dark -layout article: got\n%s", b)
+	}
+	if status := runRender([]string{"-theme", "nope", filepath.Join(dir, "deck.slide")}); status != 2 {
+		t.Errorf("unknown theme: got status %d, want 2", status)
+	}
+}
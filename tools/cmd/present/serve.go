@@ -0,0 +1,483 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Go-zh/tools/playground/socket"
+	"github.com/Tobecoder/go/tools/present"
+)
+
+// The serve command serves a directory tree. Directories are listed with
+// the title, subtitle, date and tags of the present files in them, and
+// present files are rendered with a theme when asked for. A rendered page
+// is kept until one of the files it was built from changes; it is then
+// rebuilt, and browsers showing it reload. The .play snippets of a page
+// run through the playground socket at /socket and are formatted by /fmt,
+// the transport the tour uses.
+//
+// Adding ?presenter to the URL of a slide deck gives the presenter view:
+// the current and next slides, the speaker notes and a timer. The
+// presenter view and the audience windows showing the same deck keep to
+// the same slide through a BroadcastChannel, so they must be open in the
+// same browser. Only the presenter view carries the notes; the audience
+// pages are rendered without them.
+
+//go:embed static
+var staticFiles embed.FS
+
+var (
+	dirTemplate       = template.Must(template.ParseFS(staticFiles, "static/dir.tmpl"))
+	errorTemplate     = template.Must(template.ParseFS(staticFiles, "static/error.tmpl"))
+	presenterTemplate = template.Must(template.ParseFS(staticFiles, "static/presenter.tmpl"))
+)
+
+// reloadPath is where pages listen for changes to their files.
+const reloadPath = "/_present/reload"
+
+// staticFile returns the contents of an embedded static file.
+func staticFile(name string) []byte {
+	b, err := staticFiles.ReadFile("static/" + name)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+type server struct {
+	root   string
+	theme  *theme
+	files  http.Handler
+	drafts bool // serve documents marked Draft
+
+	mu    sync.Mutex
+	pages map[string]*page // by file name
+}
+
+// A page is a present file rendered to HTML, or the errors that stopped
+// it from being rendered, with the files it was built from.
+type page struct {
+	doc  *present.Doc
+	html []byte
+	err  error
+	deps map[string]time.Time // modification times; zero if missing
+}
+
+func newServer(root string, t *theme, drafts bool) *server {
+	return &server{
+		root:   root,
+		theme:  t,
+		files:  http.FileServer(http.Dir(root)),
+		drafts: drafts,
+		pages:  make(map[string]*page),
+	}
+}
+
+// file returns the name of the file that the URL path p refers to.
+func (s *server) file(p string) string {
+	return filepath.Join(s.root, filepath.FromSlash(path.Clean("/"+p)))
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == reloadPath {
+		s.serveReload(w, r)
+		return
+	}
+	name := s.file(r.URL.Path)
+	fi, err := os.Stat(name)
+	if os.IsNotExist(err) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	switch {
+	case fi.IsDir():
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+			return
+		}
+		s.serveDir(w, r, name)
+	case isPresentFile(name):
+		s.serveDoc(w, r, name)
+	default:
+		s.files.ServeHTTP(w, r)
+	}
+}
+
+// load returns the page for the present file name, rendering it if it
+// hasn't been yet or its files have changed since.
+func (s *server) load(name string) *page {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p, ok := s.pages[name]; ok && !p.stale() {
+		return p
+	}
+	p := s.build(name)
+	s.pages[name] = p
+	return p
+}
+
+func (s *server) build(name string) *page {
+	p := &page{deps: make(map[string]time.Time)}
+	p.addDep(name)
+	ctx := &present.Context{ReadFile: func(file string) ([]byte, error) {
+		p.addDep(file)
+		return ioutil.ReadFile(file)
+	}}
+	f, err := os.Open(name)
+	if err != nil {
+		p.err = err
+		return p
+	}
+	defer f.Close()
+	if p.doc, p.err = ctx.Parse(f, name, 0); p.err != nil {
+		return p
+	}
+	layout := layoutOf(name)
+	var buf bytes.Buffer
+	if p.err = renderDoc(&buf, p.doc, name, layout, s.theme); p.err != nil {
+		return p
+	}
+	scripts := [][]byte{staticFile("reload.js")}
+	if layout == "slides" {
+		scripts = append(scripts, staticFile("sync.js"))
+	}
+	if hasPlay(p.doc) {
+		scripts = append(scripts, playgroundJS(), staticFile("play.js"))
+	}
+	p.html = injectScripts(buf.Bytes(), scripts...)
+	return p
+}
+
+func (p *page) addDep(name string) {
+	var t time.Time
+	if fi, err := os.Stat(name); err == nil {
+		t = fi.ModTime()
+	}
+	p.deps[name] = t
+}
+
+// stale reports whether any of the files p was built from has changed.
+func (p *page) stale() bool {
+	for name, t := range p.deps {
+		var mt time.Time
+		if fi, err := os.Stat(name); err == nil {
+			mt = fi.ModTime()
+		}
+		if !mt.Equal(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasPlay reports whether doc has runnable code.
+func hasPlay(doc *present.Doc) bool {
+	var walk func(elems []present.Elem) bool
+	walk = func(elems []present.Elem) bool {
+		for _, e := range elems {
+			switch e := e.(type) {
+			case present.Code:
+				if e.Play {
+					return true
+				}
+			case present.Section:
+				if walk(e.Elem) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	for _, s := range doc.Sections {
+		if walk(s.Elem) {
+			return true
+		}
+	}
+	return false
+}
+
+// injectScripts adds a script holding the given scripts to the end of the
+// body of page.
+func injectScripts(page []byte, scripts ...[]byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("<script>\n")
+	for _, js := range scripts {
+		buf.Write(js)
+		buf.WriteString("\n")
+	}
+	buf.WriteString("</script>\n")
+	i := bytes.LastIndex(page, []byte("</body>"))
+	if i < 0 {
+		return append(page, buf.Bytes()...)
+	}
+	return append(page[:i:i], append(buf.Bytes(), page[i:]...)...)
+}
+
+func (s *server) serveDoc(w http.ResponseWriter, r *http.Request, name string) {
+	p := s.load(name)
+	if p.err != nil {
+		s.serveError(w, r, p.err)
+		return
+	}
+	if p.doc.Draft && !s.drafts {
+		http.NotFound(w, r)
+		return
+	}
+	if _, ok := r.URL.Query()["presenter"]; ok {
+		if layoutOf(name) != "slides" {
+			http.Error(w, "the presenter view is only available for slides", http.StatusBadRequest)
+			return
+		}
+		s.servePresenter(w, r, p.doc)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(p.html)
+}
+
+// serveError writes a page listing the errors in the requested file.
+// It reloads when the file changes, so that it can be fixed in place.
+func (s *server) serveError(w http.ResponseWriter, r *http.Request, err error) {
+	var msgs []string
+	var list present.ErrorList
+	if errors.As(err, &list) {
+		for _, e := range list {
+			msgs = append(msgs, e.Error())
+		}
+	} else {
+		msgs = append(msgs, err.Error())
+	}
+	data := struct {
+		Name   string
+		Errors []string
+		JS     template.JS
+	}{r.URL.Path, msgs, template.JS(staticFile("reload.js"))}
+	var buf bytes.Buffer
+	if err := errorTemplate.Execute(&buf, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+	w.Write(buf.Bytes())
+}
+
+// servePresenter writes the presenter view of doc.
+func (s *server) servePresenter(w http.ResponseWriter, r *http.Request, doc *present.Doc) {
+	data := struct {
+		Title   string
+		Preview string
+		Notes   []template.HTML
+		CSS     template.CSS
+		JS      template.JS
+	}{
+		Title:   doc.Title,
+		Preview: path.Base(r.URL.Path) + "?preview",
+		Notes:   slideNotes(doc),
+		CSS:     template.CSS(staticFile("presenter.css")),
+		JS:      template.JS(string(staticFile("presenter.js")) + "\n" + string(staticFile("reload.js"))),
+	}
+	var buf bytes.Buffer
+	if err := presenterTemplate.Execute(&buf, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+// slideNotes returns the speaker notes of each slide of doc as rendered
+// by the slides layout: the title slide, one slide per section and the
+// closing slide. The notes of subsections go with their section.
+func slideNotes(doc *present.Doc) []template.HTML {
+	notes := []template.HTML{notesHTML(doc.TitleNotes)}
+	for _, s := range doc.Sections {
+		notes = append(notes, notesHTML(sectionNotes(s)))
+	}
+	return append(notes, "")
+}
+
+func sectionNotes(s present.Section) []string {
+	notes := s.Notes
+	for _, e := range s.Elem {
+		if sub, ok := e.(present.Section); ok {
+			notes = append(notes, sectionNotes(sub)...)
+		}
+	}
+	return notes
+}
+
+func notesHTML(notes []string) template.HTML {
+	var b strings.Builder
+	for _, n := range notes {
+		b.WriteString("<p>")
+		b.WriteString(string(present.Style(n)))
+		b.WriteString("</p>\n")
+	}
+	return template.HTML(b.String())
+}
+
+// serveReload streams a reload event once a file of the page named by
+// the path parameter changes.
+func (s *server) serveReload(w http.ResponseWriter, r *http.Request) {
+	name := s.file(r.FormValue("path"))
+	s.mu.Lock()
+	p := s.pages[name]
+	s.mu.Unlock()
+	if p == nil {
+		http.NotFound(w, r)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher.Flush()
+
+	tick := time.NewTicker(500 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-tick.C:
+			if p.stale() {
+				fmt.Fprint(w, "event: reload\ndata: \n\n")
+				flusher.Flush()
+				return
+			}
+		}
+	}
+}
+
+// A dirEntry is an entry of a directory listing. Present files come with
+// their header, or the error that stopped it from being read.
+type dirEntry struct {
+	Name   string
+	Slides bool
+	Doc    *present.Doc
+	Err    string
+}
+
+// serveDir lists the directory name.
+func (s *server) serveDir(w http.ResponseWriter, r *http.Request, name string) {
+	fis, err := ioutil.ReadDir(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	data := struct {
+		Path              string
+		Dirs, Docs, Files []dirEntry
+		CSS               template.CSS
+	}{Path: r.URL.Path, CSS: template.CSS(staticFile("dir.css"))}
+	for _, fi := range fis {
+		e := dirEntry{Name: fi.Name()}
+		switch {
+		case strings.HasPrefix(e.Name, "."):
+		case fi.IsDir():
+			data.Dirs = append(data.Dirs, e)
+		case isPresentFile(e.Name):
+			e.Slides = layoutOf(e.Name) == "slides"
+			if doc, err := parseTitles(filepath.Join(name, e.Name)); err != nil {
+				e.Err = err.Error()
+			} else if doc.Draft && !s.drafts {
+				continue
+			} else {
+				e.Doc = doc
+			}
+			data.Docs = append(data.Docs, e)
+		default:
+			data.Files = append(data.Files, e)
+		}
+	}
+	// Newest first, like a list of talks.
+	sort.SliceStable(data.Docs, func(i, j int) bool {
+		a, b := data.Docs[i].Doc, data.Docs[j].Doc
+		return a != nil && (b == nil || a.Time.After(b.Time))
+	})
+	var buf bytes.Buffer
+	if err := dirTemplate.Execute(&buf, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+// parseTitles reads only the header of the present file name.
+func parseTitles(name string) (*present.Doc, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return present.Parse(f, name, present.TitlesOnly)
+}
+
+// runServe implements the serve command.
+func runServe(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	httpAddr := fs.String("http", "127.0.0.1:3999", "HTTP service address")
+	themeName := fs.String("theme", defaultTheme, "theme: the name of a built-in theme or a theme directory")
+	drafts := fs.Bool("drafts", false, "list and serve documents marked Draft")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: present serve [flags] [dir]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return 2
+	}
+	root := "."
+	if fs.NArg() == 1 {
+		root = fs.Arg(0)
+	}
+	t, err := findTheme(*themeName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "present:", err)
+		return 2
+	}
+	if _, _, err := net.SplitHostPort(*httpAddr); err != nil {
+		fmt.Fprintln(os.Stderr, "present:", err)
+		return 2
+	}
+
+	present.PlayEnabled = true
+	mux := http.NewServeMux()
+	mux.Handle("/", newServer(root, t, *drafts))
+	mux.Handle("/socket", socket.NewHandler(&url.URL{Scheme: "http", Host: *httpAddr}))
+	mux.HandleFunc("/fmt", fmtHandler)
+
+	log.Printf("Open your web browser and visit http://%s/", *httpAddr)
+	log.Printf("Add ?presenter to the address of a slide deck for the presenter view.")
+	if err := http.ListenAndServe(*httpAddr, mux); err != nil {
+		fmt.Fprintln(os.Stderr, "present:", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// get returns the status and body of a GET request for path from h.
+func get(t *testing.T, h http.Handler, path string) (int, string) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+	b, err := ioutil.ReadAll(w.Result().Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w.Code, string(b)
+}
+
+func TestServeNotes(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"talk.slide":   "Talk\n: title note\n\nGopher\n\n* One\n\n: first _secret_ note\n\nText.\n\n* Two\n\nMore.\n",
+		"post.article": "Post\n\n* One\n\n: an article note\n\nText.\n",
+	})
+	th, err := findTheme(defaultTheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newServer(dir, th, false)
+
+	code, audience := get(t, s, "/talk.slide")
+	if code != http.StatusOK {
+		t.Fatalf("audience page: got status %d", code)
+	}
+	for _, note := range []string{"title note", "secret", "presenterNotes"} {
+		if strings.Contains(audience, note) {
+			t.Errorf("audience page contains %q", note)
+		}
+	}
+	if !strings.Contains(audience, "BroadcastChannel") {
+		t.Error("audience page doesn't keep in sync with the presenter")
+	}
+
+	code, presenter := get(t, s, "/talk.slide?presenter")
+	if code != http.StatusOK {
+		t.Fatalf("presenter view: got status %d", code)
+	}
+	for _, want := range []string{"title note", `first \u003ci\u003esecret\u003c/i\u003e note`, `src="talk.slide?preview#1"`} {
+		if !strings.Contains(presenter, want) {
+			t.Errorf("presenter view lacks %q", want)
+		}
+	}
+
+	if code, _ := get(t, s, "/post.article?presenter"); code != http.StatusBadRequest {
+		t.Errorf("presenter view of an article: got status %d, want %d", code, http.StatusBadRequest)
+	}
+}
+
+func TestServeDir(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		// The body is broken, but the listing only reads the header.
+		"talk.slide":    "Gophers\nA subtitle\n15 Jan 2024\nTags: go, web\n\nGopher\n\n* S\n\n.nope\n",
+		"wip.article":   "Work in progress\nDraft: true\n\n* S\n\nText.\n",
+		"bad.article":   "Title\nOrder: first\n\n* S\n",
+		"sub/a.article": "A\n\n* S\n",
+		"notes.txt":     "not a present file\n",
+		".hidden.slide": "Hidden\n\n* S\n",
+	})
+	th, err := findTheme(defaultTheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, page := get(t, newServer(dir, th, false), "/")
+	if code != http.StatusOK {
+		t.Fatalf("listing: got status %d", code)
+	}
+	for _, want := range []string{
+		`<a href="talk.slide">talk.slide</a> <a class="presenter" href="talk.slide?presenter">`,
+		`<div class="title">Gophers</div>`,
+		`<div class="subtitle">A subtitle</div>`,
+		`<span class="tag">go</span> <span class="tag">web</span>`,
+		"15 Jan 2024",
+		`<td class="error" colspan="2">`,
+		`<a href="sub/">sub/</a>`,
+		`<a href="notes.txt">notes.txt</a>`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("listing lacks %q", want)
+		}
+	}
+	for _, hidden := range []string{"wip.article", ".hidden.slide"} {
+		if strings.Contains(page, hidden) {
+			t.Errorf("listing shows %s", hidden)
+		}
+	}
+	if code, _ := get(t, newServer(dir, th, false), "/wip.article"); code != http.StatusNotFound {
+		t.Errorf("draft: got status %d, want %d", code, http.StatusNotFound)
+	}
+
+	s := newServer(dir, th, true)
+	if _, page := get(t, s, "/"); !strings.Contains(page, `Work in progress <span class="draft">draft</span>`) {
+		t.Error("listing with -drafts doesn't show the draft")
+	}
+	if code, _ := get(t, s, "/wip.article"); code != http.StatusOK {
+		t.Errorf("draft with -drafts: got status %d, want %d", code, http.StatusOK)
+	}
+	if code, _ := get(t, s, "/sub"); code != http.StatusMovedPermanently {
+		t.Errorf("directory without a slash: got status %d, want %d", code, http.StatusMovedPermanently)
+	}
+}
+
+func TestServeReload(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"talk.slide": "Talk\n\n* S\n\n.code prog.go\n",
+		"prog.go":    "package main\n",
+	})
+	th, err := findTheme(defaultTheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(newServer(dir, th, false))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + reloadPath + "?path=/talk.slide")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("reload before the page is built: got %s, want not found", resp.Status)
+	}
+	resp, err = http.Get(srv.URL + "/talk.slide")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	resp, err = http.Get(srv.URL + reloadPath + "?path=/talk.slide")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("reload: got content type %q", ct)
+	}
+
+	// Change a file the page includes, not the page itself.
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(filepath.Join(dir, "prog.go"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	event := make(chan string, 1)
+	go func() {
+		line, _ := bufio.NewReader(resp.Body).ReadString('\n')
+		event <- line
+	}()
+	select {
+	case line := <-event:
+		if line != "event: reload\n" {
+			t.Errorf("got %q, want a reload event", line)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("no reload event after the file changed")
+	}
+}
@@ -0,0 +1,99 @@
+// Adds Run and Format buttons to the .play snippets of a page. Programs
+// run over the playground socket transport, SocketTransport and
+// PlaygroundOutput, and are formatted by the /fmt handler, as in the tour.
+(function() {
+  'use strict';
+
+  var playgrounds = document.querySelectorAll('div.playground');
+  if (playgrounds.length === 0) {
+    return;
+  }
+  window.socketAddr = (location.protocol === 'https:' ? 'wss://' : 'ws://') + location.host + '/socket';
+  var transport = SocketTransport();
+
+  // text returns the program shown by node, including the hidden code
+  // around the snippet.
+  function text(node) {
+    var s = '';
+    for (var i = 0; i < node.childNodes.length; i++) {
+      var n = node.childNodes[i];
+      if (n.nodeType === 1) {
+        if (n.tagName === 'BUTTON' || n.classList.contains('output')) {
+          continue;
+        }
+        if (n.tagName === 'DIV' || n.tagName === 'BR' || n.tagName === 'PRE') {
+          s += '\n';
+        }
+        s += text(n);
+      } else if (n.nodeType === 3) {
+        s += n.nodeValue;
+      }
+    }
+    return s.replace(/\xA0/g, ' ');
+  }
+
+  function button(label, onclick) {
+    var b = document.createElement('button');
+    b.textContent = label;
+    b.addEventListener('click', onclick);
+    return b;
+  }
+
+  function init(code) {
+    var running;
+    var output = document.createElement('div');
+    output.className = 'output';
+    var out = document.createElement('pre');
+    output.appendChild(out);
+
+    function stop() {
+      if (running) {
+        running.Kill();
+        running = null;
+      }
+    }
+    function run() {
+      stop();
+      out.textContent = '';
+      output.style.display = 'block';
+      running = transport.Run(text(code), PlaygroundOutput(out));
+    }
+    function format() {
+      var params = new URLSearchParams({body: text(code), imports: 'true'});
+      fetch('/fmt', {method: 'POST', body: params}).then(function(r) {
+        return r.json();
+      }).then(function(res) {
+        if (res.Error) {
+          out.textContent = res.Error;
+          output.style.display = 'block';
+          return;
+        }
+        // The formatted program replaces the snippet and the code around it.
+        var pres = code.querySelectorAll('pre');
+        for (var i = 0; i < pres.length; i++) {
+          if (pres[i].style.display === 'none') {
+            pres[i].textContent = '';
+          } else {
+            pres[i].textContent = res.Body;
+          }
+        }
+      });
+    }
+    function close() {
+      stop();
+      output.style.display = 'none';
+    }
+
+    var buttons = document.createElement('div');
+    buttons.className = 'buttons';
+    buttons.appendChild(button('Run', run));
+    buttons.appendChild(button('Format', format));
+    buttons.appendChild(button('Close', close));
+    code.appendChild(buttons);
+    code.appendChild(output);
+  }
+
+  for (var i = 0; i < playgrounds.length; i++) {
+    init(playgrounds[i]);
+  }
+})();
@@ -0,0 +1,103 @@
+// The presenter view: the current and next slides, the speaker notes and
+// a timer. It drives the audience windows over the deck's BroadcastChannel.
+(function() {
+  'use strict';
+
+  var notes = window.presenterNotes;
+  var count = notes.length;
+  var current = 0;
+  var base = location.pathname + '?preview';
+  var currentFrame = document.getElementById('current-slide');
+  var nextFrame = document.getElementById('next-slide');
+  var notesBox = document.getElementById('notes');
+  var position = document.getElementById('position');
+  var channel = window.BroadcastChannel ? new BroadcastChannel('present:' + location.pathname) : null;
+
+  function show(n, announce) {
+    current = Math.max(0, Math.min(count - 1, n));
+    currentFrame.src = base + '#' + (current + 1);
+    nextFrame.style.visibility = current + 1 < count ? 'visible' : 'hidden';
+    if (current + 1 < count) {
+      nextFrame.src = base + '#' + (current + 2);
+    }
+    notesBox.innerHTML = notes[current] || '<p class="none">No notes for this slide.</p>';
+    position.textContent = (current + 1) + ' / ' + count;
+    if (announce && channel) {
+      channel.postMessage({slide: current});
+    }
+  }
+
+  if (channel) {
+    channel.onmessage = function(e) {
+      if (typeof e.data.slide === 'number' && e.data.slide !== current) {
+        show(e.data.slide, false);
+      }
+    };
+    // Ask open audience windows where they are.
+    channel.postMessage({hello: true});
+  }
+
+  document.addEventListener('keydown', function(e) {
+    if (e.altKey || e.ctrlKey || e.metaKey) {
+      return;
+    }
+    switch (e.key) {
+    case 'ArrowRight': case 'ArrowDown': case 'PageDown': case ' ': case 'l': case 'j':
+      show(current + 1, true);
+      break;
+    case 'ArrowLeft': case 'ArrowUp': case 'PageUp': case 'Backspace': case 'h': case 'k':
+      show(current - 1, true);
+      break;
+    case 'Home':
+      show(0, true);
+      break;
+    case 'End':
+      show(count - 1, true);
+      break;
+    default:
+      return;
+    }
+    e.preventDefault();
+  });
+
+  document.getElementById('open').addEventListener('click', function() {
+    window.open(location.pathname + '#' + (current + 1), 'present-audience');
+  });
+
+  // The timer counts the time spent presenting, excluding pauses.
+  var timer = document.getElementById('timer');
+  var toggle = document.getElementById('timer-toggle');
+  var elapsed = 0;
+  var started = null;
+
+  function seconds() {
+    return Math.floor((elapsed + (started ? Date.now() - started : 0)) / 1000);
+  }
+  function tick() {
+    var s = seconds();
+    var m = Math.floor(s / 60);
+    s %= 60;
+    timer.textContent = m + ':' + (s < 10 ? '0' : '') + s;
+  }
+  toggle.addEventListener('click', function() {
+    if (started) {
+      elapsed += Date.now() - started;
+      started = null;
+      toggle.textContent = 'Start';
+    } else {
+      started = Date.now();
+      toggle.textContent = 'Pause';
+    }
+    tick();
+  });
+  document.getElementById('timer-reset').addEventListener('click', function() {
+    elapsed = 0;
+    if (started) {
+      started = Date.now();
+    }
+    tick();
+  });
+  setInterval(tick, 1000);
+
+  show(0, false);
+})();
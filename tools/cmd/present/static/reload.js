@@ -0,0 +1,13 @@
+// Reloads the page when the files it was built from change.
+(function() {
+  'use strict';
+
+  if (!window.EventSource) {
+    return;
+  }
+  var events = new EventSource('/_present/reload?path=' + encodeURIComponent(location.pathname));
+  events.addEventListener('reload', function() {
+    events.close();
+    location.reload();
+  });
+})();
@@ -0,0 +1,29 @@
+// Keeps the windows showing a deck on the same slide. Every window except
+// the presenter's previews joins a BroadcastChannel named after the deck
+// and announces the slides it moves to.
+(function() {
+  'use strict';
+
+  if (!window.BroadcastChannel || !window.slides || /[?&]preview\b/.test(location.search)) {
+    return;
+  }
+  var channel = new BroadcastChannel('present:' + location.pathname);
+  var remote = false;
+
+  document.addEventListener('slidechange', function(e) {
+    if (!remote) {
+      channel.postMessage({slide: e.detail});
+    }
+  });
+  channel.onmessage = function(e) {
+    if (e.data.hello) {
+      channel.postMessage({slide: window.slides.current()});
+      return;
+    }
+    if (typeof e.data.slide === 'number') {
+      remote = true;
+      window.slides.show(e.data.slide);
+      remote = false;
+    }
+  };
+})();
@@ -0,0 +1,88 @@
+// Keyboard, click and touch navigation for slide decks. The number of the
+// current slide, starting at 1, is kept in the URL fragment.
+(function() {
+  'use strict';
+
+  var slides = document.querySelectorAll('section.deck > article');
+  var current = -1;
+
+  function show(n) {
+    n = Math.max(0, Math.min(slides.length - 1, n));
+    if (n === current) {
+      return;
+    }
+    if (current >= 0) {
+      slides[current].classList.remove('current');
+    }
+    current = n;
+    slides[current].classList.add('current');
+    if (location.hash !== '#' + (n + 1)) {
+      history.replaceState(null, '', '#' + (n + 1));
+    }
+    document.dispatchEvent(new CustomEvent('slidechange', {detail: current}));
+  }
+
+  function fromHash() {
+    var n = parseInt(location.hash.slice(1), 10);
+    return isNaN(n) ? 0 : n - 1;
+  }
+
+  document.addEventListener('keydown', function(e) {
+    if (e.altKey || e.ctrlKey || e.metaKey) {
+      return;
+    }
+    if (e.target.isContentEditable || /^(INPUT|TEXTAREA|SELECT)$/.test(e.target.tagName)) {
+      return;
+    }
+    switch (e.key) {
+    case 'ArrowRight': case 'ArrowDown': case 'PageDown': case ' ': case 'l': case 'j':
+      show(current + 1);
+      break;
+    case 'ArrowLeft': case 'ArrowUp': case 'PageUp': case 'Backspace': case 'h': case 'k':
+      show(current - 1);
+      break;
+    case 'Home':
+      show(0);
+      break;
+    case 'End':
+      show(slides.length - 1);
+      break;
+    default:
+      return;
+    }
+    e.preventDefault();
+  });
+
+  document.addEventListener('click', function(e) {
+    if (e.target.closest('a, div.code, [contenteditable]')) {
+      return;
+    }
+    show(current + (e.clientX > window.innerWidth / 3 ? 1 : -1));
+  });
+
+  var touchX = null;
+  document.addEventListener('touchstart', function(e) {
+    touchX = e.touches[0].clientX;
+  });
+  document.addEventListener('touchend', function(e) {
+    if (touchX === null) {
+      return;
+    }
+    var dx = e.changedTouches[0].clientX - touchX;
+    touchX = null;
+    if (Math.abs(dx) > 50) {
+      show(current + (dx < 0 ? 1 : -1));
+    }
+  });
+
+  window.addEventListener('hashchange', function() {
+    show(fromHash());
+  });
+
+  window.slides = {
+    show: show,
+    current: function() { return current; },
+    count: slides.length
+  };
+  show(fromHash());
+})();
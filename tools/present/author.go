@@ -0,0 +1,138 @@
+package present
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+/*
+	Each author is a block of lines after the header. Lines may be typed
+	with a prefix,
+
+		Email: gopher@example.com
+		GitHub: gopher
+		Mastodon: @gopher@hachyderm.io
+		Affiliation: Gopher Inc.
+		Homepage: https://example.com/
+
+	and untyped lines are recognised by their form: an absolute URL is a
+	link, an email address is a mailto link, @name is a Twitter name and
+	@user@host a Mastodon handle; the first of each kind also fills in
+	the Author's field. Anything else is text, the first line of it the
+	author's name. Text with a colon is not taken for a URL.
+*/
+
+var (
+	urlLineRE      = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.-]*://\S+$`)
+	emailRE        = regexp.MustCompile(`^[^\s@:/]+@[^\s@:/]+\.[^\s@:/]+$`)
+	mastodonRE     = regexp.MustCompile(`^@?([A-Za-z0-9_]+)@([A-Za-z0-9.-]+\.[A-Za-z]{2,})$`)
+	githubRE       = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?$`)
+	githubURLRE    = regexp.MustCompile(`^(?:https?://)?github\.com/([^/\s]+)/?$`)
+	twitterRE      = regexp.MustCompile(`^@([A-Za-z0-9_]+)$`)
+	authorPrefixes = []string{"Email:", "GitHub:", "Mastodon:", "Affiliation:", "Homepage:"}
+)
+
+// authorValueColumn returns the column of the value in the author line
+// text, after its prefix if it has one.
+func authorValueColumn(text string) int {
+	for _, prefix := range authorPrefixes {
+		if strings.HasPrefix(text, prefix) {
+			return valueColumn(text, prefix)
+		}
+	}
+	return valueColumn(text, "")
+}
+
+// parseAuthorLine parses a line of the details of author a, filling in
+// its fields. It returns the element that shows the line, if any.
+func parseAuthorLine(a *Author, text string) (Elem, error) {
+	text = trimRight(text)
+	for _, prefix := range authorPrefixes {
+		if strings.HasPrefix(text, prefix) {
+			return a.typedLine(text, prefix, strings.TrimSpace(text[len(prefix):]))
+		}
+	}
+	switch {
+	case urlLineRE.MatchString(text):
+		u, err := url.Parse(text)
+		if err != nil {
+			return nil, err
+		}
+		if a.Homepage == "" {
+			a.Homepage = text
+		}
+		return Link{URL: u, Label: text}, nil
+	case twitterRE.MatchString(text):
+		if a.Twitter == "" {
+			a.Twitter = text[1:]
+		}
+		return Link{URL: &url.URL{Scheme: "https", Host: "twitter.com", Path: "/" + text[1:]}, Label: text}, nil
+	case strings.HasPrefix(text, "@") && mastodonRE.MatchString(text):
+		return a.mastodon(text, "")
+	case emailRE.MatchString(text):
+		if a.Email == "" {
+			a.Email = text
+		}
+		return Link{URL: &url.URL{Scheme: "mailto", Opaque: text}, Label: text}, nil
+	}
+	if a.Name == "" {
+		a.Name = text
+	}
+	return Text{Lines: []string{text}}, nil
+}
+
+// typedLine parses the line text, which starts with prefix and has the
+// value v.
+func (a *Author) typedLine(text, prefix, v string) (Elem, error) {
+	switch prefix {
+	case "Email:":
+		v = strings.TrimPrefix(v, "mailto:")
+		if !emailRE.MatchString(v) {
+			return nil, fmt.Errorf("bad email address %q", v)
+		}
+		a.Email = v
+		return Link{Cmd: text, URL: &url.URL{Scheme: "mailto", Opaque: v}, Label: v}, nil
+	case "GitHub:":
+		if m := githubURLRE.FindStringSubmatch(v); m != nil {
+			v = m[1]
+		}
+		v = strings.TrimPrefix(v, "@")
+		if !githubRE.MatchString(v) {
+			return nil, fmt.Errorf("bad GitHub user name %q", v)
+		}
+		a.GitHub = v
+		return Link{Cmd: text, URL: &url.URL{Scheme: "https", Host: "github.com", Path: "/" + v}, Label: "github.com/" + v}, nil
+	case "Mastodon:":
+		return a.mastodon(v, text)
+	case "Affiliation:":
+		if v == "" {
+			return nil, fmt.Errorf("empty affiliation")
+		}
+		a.Affiliation = v
+		return nil, nil
+	case "Homepage:":
+		if !urlLineRE.MatchString(v) {
+			return nil, fmt.Errorf("bad homepage %q: must be an absolute URL", v)
+		}
+		u, err := url.Parse(v)
+		if err != nil {
+			return nil, err
+		}
+		a.Homepage = v
+		return Link{Cmd: text, URL: u, Label: v}, nil
+	}
+	panic("unknown author line prefix " + prefix)
+}
+
+// mastodon parses the Mastodon handle v, given on the line cmd or on an
+// untyped line if cmd is empty.
+func (a *Author) mastodon(v, cmd string) (Elem, error) {
+	m := mastodonRE.FindStringSubmatch(v)
+	if m == nil {
+		return nil, fmt.Errorf("bad Mastodon handle %q: must be @user@host", v)
+	}
+	a.Mastodon = "@" + m[1] + "@" + m[2]
+	return Link{Cmd: cmd, URL: &url.URL{Scheme: "https", Host: m[2], Path: "/@" + m[1]}, Label: a.Mastodon}, nil
+}
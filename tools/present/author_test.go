@@ -0,0 +1,75 @@
+package present
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAuthors(t *testing.T) {
+	const src = `Title
+
+Gopher
+高级工程师：编译器组
+Affiliation: Gopher Inc.
+Email: gopher@example.com
+GitHub: https://github.com/gopher
+Mastodon: gopher@hachyderm.io
+https://example.com/
+@gopher
+gopher2@example.com
+
+Rob
+@rob@mastodon.social
+Go 团队: 工具
+
+* Intro
+
+Text.
+`
+	doc, err := testContext().Parse(strings.NewReader(src), "doc.slide", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := doc.Authors[0]
+	want := Author{
+		Name:        "Gopher",
+		Affiliation: "Gopher Inc.",
+		Email:       "gopher@example.com",
+		Homepage:    "https://example.com/",
+		GitHub:      "gopher",
+		Mastodon:    "@gopher@hachyderm.io",
+		Twitter:     "gopher",
+	}
+	a.Elem = nil
+	if !reflect.DeepEqual(a, want) {
+		t.Errorf("got %+v\nwant %+v", a, want)
+	}
+	var links []string
+	for _, e := range doc.Authors[0].Links() {
+		links = append(links, e.(Link).URL.String())
+	}
+	wantLinks := "mailto:gopher@example.com https://github.com/gopher https://hachyderm.io/@gopher https://example.com/ https://twitter.com/gopher mailto:gopher2@example.com"
+	if got := strings.Join(links, " "); got != wantLinks {
+		t.Errorf("got links %s\nwant %s", got, wantLinks)
+	}
+	if n := len(doc.Authors[0].TextElem()); n != 2 {
+		t.Errorf("got %d text elements, want 2", n)
+	}
+
+	rob := doc.Authors[1]
+	if rob.Mastodon != "@rob@mastodon.social" || rob.Name != "Rob" {
+		t.Errorf("got %+v", rob)
+	}
+	if _, ok := rob.Elem[2].(Text); !ok {
+		t.Errorf("text with a colon: got %T, want Text", rob.Elem[2])
+	}
+	checkRoundTrip(t, testContext(), "doc.slide", doc)
+
+	for _, bad := range []string{"Email: nobody", "Mastodon: @nohost", "GitHub: -x-"} {
+		_, err := testContext().Parse(strings.NewReader("Title\n\nName\n"+bad+"\n\n* S\n"), "bad.slide", 0)
+		if err == nil {
+			t.Errorf("%q: no error", bad)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package present
+
+func init() {
+	RegisterCommand(&Command{
+		Name:  "caption",
+		Doc:   "Captions the image or video above it.",
+		Args:  []Arg{{Name: "text", Type: TextArg, Doc: "the caption, with inline markup"}},
+		Parse: parseCaption,
+	})
+}
+
+// Caption is a caption for the element above it, usually an image or a
+// video. Its text holds inline markup.
+type Caption struct {
+	Cmd  string // original command from present source
+	Text string
+}
+
+func (c Caption) TemplateName() string { return "caption" }
+
+func parseCaption(_ *Context, c *CommandLine) (Elem, error) {
+	return Caption{Cmd: c.Cmd, Text: c.String("text")}, nil
+}
@@ -1,23 +1,243 @@
 package present
 
-import "regexp"
+import (
+	"bufio"
+	"bytes"
+	"html/template"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
 
+// PlayEnabled specifies whether runnable playground snippets should be
+// displayed in the present user interface.
 var PlayEnabled = false
 
 func init() {
-	Register("code", parseCode)
-	Register("play", parseCode)
+	for name, doc := range map[string]string{
+		"code": "Shows source code from a file.",
+		"play": "Shows source code from a file that the reader can run.",
+	} {
+		RegisterCommand(&Command{
+			Name: name,
+			Doc:  doc,
+			Flags: []Flag{
+				{Name: "numbers", Type: BoolArg, Doc: "number the lines"},
+				{Name: "edit", Type: BoolArg, Doc: "let the reader edit the code"},
+			},
+			Args: []Arg{
+				{Name: "file", Type: PathArg, Doc: "the source file"},
+				{Name: "address", Type: TextArg, Optional: true, Doc: "the lines to show, such as /^func main/,/^}/, then HLname to highlight the lines marked // HLname"},
+			},
+			Parse: parseCode,
+		})
+	}
+}
+
+type Code struct {
+	Cmd         string // original command from present source
+	Text        template.HTML
+	Play        bool   // runnable code
+	Edit        bool   // editable code
+	FileName    string // file name
+	Ext         string // file extension
+	Raw         []byte // content of the file
+	Prog        []byte // whole program run by a .play snippet, hidden parts included
+	Output      *Output
+	Highlight   string `json:",omitempty"` // name of the HL marker ending the address, without the HL
+	Highlighted bool   `json:",omitempty"` // whether any line is marked with the highlight
 }
 
+// Output is the output of a run of a .play snippet, recorded when the
+// document was built so that it can be shown without running the code.
+type Output struct {
+	Text  string // standard output and standard error, interleaved
+	Error string // why the program failed, if it did: a build failure, exit status or timeout
+}
+
+func (c Code) TemplateName() string { return "code" }
+
+// The address of a .code or .play entry may end with an HLfoo marker.
+// We pick off the HL first; the rest is an address expression, which we
+// treat as a string here.
 var (
-	highlightRE = regexp.MustCompile(`\s+HL([a-zA-Z0-9_]+)?$`)
+	highlightRE = regexp.MustCompile(`(?:^|\s+)HL([a-zA-Z0-9_]+)?$`)
 	hlCommentRE = regexp.MustCompile(`(.+) // HL(.*)$`)
-	codeRE      = regexp.MustCompile(`\.(code|play)\s+((?:(?:-edit|-numbers)\s+)*)([^\s]+)(?:\s+(.*))?$`)
 )
 
+// parseCode parses a code present directive. Its syntax:
+//
+//	.code [-numbers] [-edit] <filename> [address] [highlight]
+//
+// The directive may also be ".play" if the snippet is executable.
+func parseCode(ctx *Context, c *CommandLine) (Elem, error) {
+	// Pull off the HL, if any, from the end of the address.
+	addr, highlight := c.String("address"), ""
+	if hl := highlightRE.FindStringSubmatchIndex(addr); hl != nil {
+		if hl[2] < 0 {
+			return nil, c.Errorf("address", "invalid highlight syntax")
+		}
+		highlight = addr[hl[2]:hl[3]]
+		addr = addr[:hl[0]]
+	}
+	play := c.Name == "play" && PlayEnabled
+
+	// Read in code file and (optionally) match address.
+	filename := c.Path("file")
+	textBytes, err := c.ReadFile(ctx, "file")
+	if err != nil {
+		return nil, err
+	}
+	lo, hi, err := addrToByteRange(addr, 0, textBytes)
+	if err != nil {
+		e := c.Errorf("address", "%v", err)
+		e.Err = err
+		return nil, e
+	}
+	if lo > hi {
+		// The search in addrToByteRange can wrap around so we might
+		// end up with the range ending before its starting point
+		hi, lo = lo, hi
+	}
+
+	// Acme pattern matches can stop mid-line,
+	// so run to end of line in both directions if not at line start/end.
+	for lo > 0 && textBytes[lo-1] != '\n' {
+		lo--
+	}
+	if hi > 0 {
+		for hi < len(textBytes) && textBytes[hi-1] != '\n' {
+			hi++
+		}
+	}
+
+	lines := codeLines(textBytes, lo, hi)
+
+	data := &codeTemplateData{
+		Lines:   formatLines(lines, highlight),
+		Edit:    c.Flag("edit"),
+		Numbers: c.Flag("numbers"),
+	}
+	highlightLines(data.Lines, strings.TrimPrefix(filepath.Ext(filename), "."))
+
+	// Include before and after in a hidden span for playground code.
+	if play {
+		data.Prefix = textBytes[:lo]
+		data.Suffix = textBytes[hi:]
+	}
+
+	var buf bytes.Buffer
+	if err := codeTemplate.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	code := Code{
+		Cmd:       c.Cmd,
+		Text:      template.HTML(buf.String()),
+		Play:      play,
+		Edit:      data.Edit,
+		FileName:  filepath.Base(filename),
+		Ext:       filepath.Ext(filename),
+		Raw:       rawCode(lines),
+		Highlight: highlight,
+	}
+	for _, l := range data.Lines {
+		code.Highlighted = code.Highlighted || l.HL
+	}
+	if c.Name == "play" {
+		code.Prog = append(append(append([]byte(nil), textBytes[:lo]...), code.Raw...), textBytes[hi:]...)
+	}
+	return code, nil
+}
+
+// formatLines returns a new slice of codeLine with the given lines
+// replacing tabs with spaces and adding highlighting where needed.
+func formatLines(lines []codeLine, highlight string) []codeLine {
+	formatted := make([]codeLine, len(lines))
+	for i, line := range lines {
+		// Replace tabs with spaces, which work better in HTML.
+		line.L = strings.Replace(line.L, "\t", "    ", -1)
+
+		// Highlight lines that end with "// HL[highlight]"
+		// and strip the magic comment.
+		if m := hlCommentRE.FindStringSubmatch(line.L); m != nil {
+			line.L = m[1]
+			line.HL = m[2] == highlight
+		}
+
+		formatted[i] = line
+	}
+	return formatted
+}
 
-func parseCode(ctx *Context, sourceFile string, sourceLine int, cmd string) (Elem, error) {
-	var e Elem
+// rawCode returns the code represented by the given codeLines without any kind
+// of formatting.
+func rawCode(lines []codeLine) []byte {
+	b := new(bytes.Buffer)
+	for _, line := range lines {
+		b.WriteString(line.L)
+		b.WriteByte('\n')
+	}
+	return b.Bytes()
+}
+
+type codeTemplateData struct {
+	Lines          []codeLine
+	Prefix, Suffix []byte
+	Edit, Numbers  bool
+}
+
+var leadingSpaceRE = regexp.MustCompile(`^[ \t]*`)
+
+var codeTemplate = template.Must(template.New("code").Funcs(template.FuncMap{
+	"leadingSpace": leadingSpaceRE.FindString,
+}).Parse(codeTemplateHTML))
+
+const codeTemplateHTML = `
+{{with .Prefix}}<pre style="display: none"><span>{{printf "%s" .}}</span></pre>{{end -}}
+
+<pre{{if .Edit}} contenteditable="true" spellcheck="false"{{end}}{{if .Numbers}} class="numbers"{{end}}>{{/*
+	*/}}{{range .Lines}}<span num="{{.N}}">{{/*
+	*/}}{{leadingSpace .L}}{{if .HL}}<b>{{.H}}</b>{{else}}{{.H}}{{end}}{{/*
+*/}}</span>
+{{end}}</pre>
+{{with .Suffix}}<pre style="display: none"><span>{{printf "%s" .}}</span></pre>{{end -}}
+`
+
+// codeLine represents a line of code extracted from a source file.
+type codeLine struct {
+	L  string        // The line of code.
+	N  int           // The line number from the source file.
+	HL bool          // Whether the line should be highlighted.
+	H  template.HTML // The line after its leading space, as HTML with the tokens classed.
+}
 
-	return e, nil
+// codeLines takes a source file and returns the lines that
+// span the byte range specified by start and end.
+// It discards lines that end in "OMIT".
+func codeLines(src []byte, start, end int) (lines []codeLine) {
+	startLine := 1
+	for i, b := range src {
+		if i == start {
+			break
+		}
+		if b == '\n' {
+			startLine++
+		}
+	}
+	s := bufio.NewScanner(bytes.NewReader(src[start:end]))
+	for n := startLine; s.Scan(); n++ {
+		l := s.Text()
+		if strings.HasSuffix(l, "OMIT") {
+			continue
+		}
+		lines = append(lines, codeLine{L: l, N: n})
+	}
+	// Trim leading and trailing blank lines.
+	for len(lines) > 0 && len(lines[0].L) == 0 {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && len(lines[len(lines)-1].L) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	return
 }
@@ -0,0 +1,375 @@
+package present
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+	Commands registered with RegisterCommand declare their flags and
+	arguments instead of parsing the line themselves:
+
+		RegisterCommand(&Command{
+			Name: "iframe",
+			Doc:  "Embeds another page.",
+			Args: []Arg{
+				{Name: "url", Type: URLArg, Doc: "the page"},
+				{Name: "width", Type: IntArg, Optional: true},
+				{Name: "height", Type: IntArg, Paired: true},
+			},
+			Parse: parseIframe,
+		})
+
+	Flags come first, each a word starting with -; the arguments follow
+	in order. The package checks the line against the declaration and
+	reports mistakes at the column of the word at fault, or of the end of
+	the line for a missing argument, along with the command's usage. The
+	declarations also make up the reference printed by present help
+	commands.
+*/
+
+// An ArgType is the kind of value a flag or argument takes.
+type ArgType int
+
+const (
+	StringArg ArgType = iota // a word
+	IntArg                   // a positive number, or _ for none
+	PathArg                  // a file, relative to the document
+	URLArg                   // an http or https URL, or a file as for PathArg
+	TextArg                  // the rest of the line; only as the last argument
+	BoolArg                  // for flags: set if present
+)
+
+// Hint describes the values of type t for the command reference.
+func (t ArgType) Hint() string {
+	switch t {
+	case IntArg:
+		return "number or _"
+	case PathArg:
+		return "file"
+	case URLArg:
+		return "URL or file"
+	case TextArg:
+		return "text"
+	}
+	return ""
+}
+
+// A Flag is an optional -name word before the arguments of a command. A
+// flag that isn't a BoolArg takes the next word as its value.
+type Flag struct {
+	Name string // without the -
+	Type ArgType
+	Doc  string
+}
+
+// An Arg is a positional argument of a command.
+type Arg struct {
+	Name     string
+	Type     ArgType
+	Optional bool     // optional arguments follow the required ones
+	Paired   bool     // given if and only if the argument before it is
+	Choices  []string // the values allowed, if limited
+	Doc      string
+}
+
+// A Command describes a command for RegisterCommand or Describe.
+type Command struct {
+	Name     string // without the leading dot
+	Doc      string // a sentence or two for the command reference
+	Synopsis string // the usage line of a command that parses its own arguments
+	Block    bool   // the indented block below the command is its body
+	Flags    []Flag
+	Args     []Arg
+	Parse    func(ctx *Context, c *CommandLine) (Elem, error)
+}
+
+// Usage returns the usage line of the command, such as
+//
+//	.iframe url [width height]
+func (cmd *Command) Usage() string {
+	if cmd.Synopsis != "" {
+		return cmd.Synopsis
+	}
+	var b strings.Builder
+	b.WriteString("." + cmd.Name)
+	for _, f := range cmd.Flags {
+		b.WriteString(" [-" + f.Name)
+		switch f.Type {
+		case BoolArg:
+		case IntArg:
+			b.WriteString(" n")
+		default:
+			b.WriteString(" value")
+		}
+		b.WriteString("]")
+	}
+	optional := 0
+	for _, a := range cmd.Args {
+		b.WriteString(" ")
+		if a.Optional && !a.Paired {
+			b.WriteString("[")
+			optional++
+		}
+		if len(a.Choices) > 0 {
+			b.WriteString(strings.Join(a.Choices, "|"))
+		} else {
+			b.WriteString(a.Name)
+		}
+		if a.Type == TextArg {
+			b.WriteString("...")
+		}
+	}
+	b.WriteString(strings.Repeat("]", optional))
+	return b.String()
+}
+
+var commands = make(map[string]*Command)
+
+// RegisterCommand registers cmd, whose arguments are parsed and checked
+// before its Parse function is called.
+func RegisterCommand(cmd *Command) {
+	if len(cmd.Name) == 0 || cmd.Name[0] == ';' || cmd.Parse == nil {
+		panic("bad command in RegisterCommand: " + cmd.Name)
+	}
+	for i, a := range cmd.Args {
+		if a.Paired {
+			a.Optional = i > 0 && cmd.Args[i-1].Optional
+			cmd.Args[i] = a
+		}
+		if a.Type == BoolArg || a.Type == TextArg && i != len(cmd.Args)-1 || !a.Optional && i > 0 && cmd.Args[i-1].Optional {
+			panic("bad argument " + a.Name + " of command " + cmd.Name)
+		}
+	}
+	for _, f := range cmd.Flags {
+		if f.Type == TextArg {
+			panic("bad flag " + f.Name + " of command " + cmd.Name)
+		}
+	}
+	commands[cmd.Name] = cmd
+	if cmd.Block {
+		blockParsers["."+cmd.Name] = func(ctx *Context, fileName string, lineno int, text string, body []string) (Elem, error) {
+			return cmd.parse(ctx, fileName, lineno, text, body)
+		}
+		return
+	}
+	parsers["."+cmd.Name] = func(ctx *Context, fileName string, lineno int, text string) (Elem, error) {
+		return cmd.parse(ctx, fileName, lineno, text, nil)
+	}
+}
+
+// Describe adds a command registered with Register or RegisterBlock, or
+// built into the parser, to the command reference.
+func Describe(name, synopsis, doc string) {
+	commands[name] = &Command{Name: name, Synopsis: synopsis, Doc: doc}
+}
+
+// Commands returns the commands in the command reference, sorted by name.
+func Commands() []*Command {
+	var list []*Command
+	for _, cmd := range commands {
+		list = append(list, cmd)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+func init() {
+	Describe("include", ".include file", "Includes the sections of another present file.")
+	Describe("background", ".background image", "Sets the background image of the slide.")
+}
+
+// A CommandLine is a command parsed according to its declaration.
+type CommandLine struct {
+	Name string   // the command, without the dot
+	Cmd  string   // original command from present source
+	Body []string // indented lines following a block command
+	File string
+	Line int
+
+	cmd    *Command
+	values map[string]argValue
+}
+
+type argValue struct {
+	text string
+	col  int
+	path string // the resolved path of a PathArg or local URLArg
+}
+
+// Has reports whether the flag or argument name was given.
+func (c *CommandLine) Has(name string) bool {
+	_, ok := c.values[name]
+	return ok
+}
+
+// Flag reports whether the BoolArg flag name was given.
+func (c *CommandLine) Flag(name string) bool { return c.Has(name) }
+
+// String returns the value of the flag or argument name as written, or
+// "" if it wasn't given.
+func (c *CommandLine) String(name string) string { return c.values[name].text }
+
+// Int returns the value of the IntArg flag or argument name, or 0 if it
+// wasn't given or was _.
+func (c *CommandLine) Int(name string) int {
+	n, _ := strconv.Atoi(c.values[name].text)
+	return n
+}
+
+// Path returns the path of the PathArg name resolved against the
+// document, or "" if it wasn't given.
+func (c *CommandLine) Path(name string) string { return c.values[name].path }
+
+// ReadFile reads the file given by the PathArg name.
+func (c *CommandLine) ReadFile(ctx *Context, name string) ([]byte, error) {
+	b, err := ctx.readFile(c.Path(name))
+	if err != nil {
+		return nil, &ParseError{File: c.File, Line: c.Line, Column: c.column(name), Code: CodeReadFile, Msg: err.Error(), Err: err}
+	}
+	return b, nil
+}
+
+// Errorf returns a CodeBadCommand error at the flag or argument name, or
+// at the command if name wasn't given.
+func (c *CommandLine) Errorf(name, format string, args ...interface{}) *ParseError {
+	return errorf(c.File, c.Line, c.column(name), CodeBadCommand, format, args...)
+}
+
+func (c *CommandLine) column(name string) int {
+	if v, ok := c.values[name]; ok {
+		return v.col
+	}
+	return 1
+}
+
+// usageError returns a CodeBadCommand error at column col that ends
+// with the command's usage.
+func (c *CommandLine) usageError(col int, format string, args ...interface{}) *ParseError {
+	return errorf(c.File, c.Line, col, CodeBadCommand, "%s; usage: %s", fmt.Sprintf(format, args...), c.cmd.Usage())
+}
+
+type word struct {
+	text string
+	col  int
+}
+
+// words splits s into words, noting the column at which each starts.
+func words(s string) []word {
+	var list []word
+	for i := 0; i < len(s); {
+		if s[i] == ' ' || s[i] == '\t' {
+			i++
+			continue
+		}
+		j := i
+		for j < len(s) && s[j] != ' ' && s[j] != '\t' {
+			j++
+		}
+		list = append(list, word{s[i:j], i + 1})
+		i = j
+	}
+	return list
+}
+
+// parse parses the command line text, with the block body, and calls
+// cmd.Parse with the result.
+func (cmd *Command) parse(ctx *Context, fileName string, lineno int, text string, body []string) (Elem, error) {
+	text = trimRight(text)
+	c := &CommandLine{Name: cmd.Name, Cmd: strings.TrimSpace(text), Body: body, File: fileName, Line: lineno, cmd: cmd, values: make(map[string]argValue)}
+	ws := words(text)[1:]
+	end := len(text) + 1
+	for len(cmd.Flags) > 0 && len(ws) > 0 && len(ws[0].text) > 1 && ws[0].text[0] == '-' {
+		w := ws[0]
+		ws = ws[1:]
+		f := cmd.flag(w.text[1:])
+		if f == nil {
+			return nil, c.usageError(w.col, "unknown flag %s", w.text)
+		}
+		v := argValue{text: "true", col: w.col}
+		if f.Type != BoolArg {
+			if len(ws) == 0 {
+				return nil, c.usageError(end, "flag %s needs a value", w.text)
+			}
+			v = argValue{text: ws[0].text, col: ws[0].col}
+			ws = ws[1:]
+		}
+		if err := c.check(ctx, f.Name, f.Type, nil, &v); err != nil {
+			return nil, err
+		}
+		c.values[f.Name] = v
+	}
+	for i, a := range cmd.Args {
+		if len(ws) == 0 {
+			if !a.Optional || a.Paired && c.Has(cmd.Args[i-1].Name) {
+				return nil, c.usageError(end, "missing %s", a.Name)
+			}
+			break
+		}
+		v := argValue{text: ws[0].text, col: ws[0].col}
+		ws = ws[1:]
+		if a.Type == TextArg {
+			v.text = text[v.col-1:]
+			ws = nil
+		}
+		if err := c.check(ctx, a.Name, a.Type, a.Choices, &v); err != nil {
+			return nil, err
+		}
+		c.values[a.Name] = v
+	}
+	if len(ws) > 0 {
+		return nil, c.usageError(ws[0].col, "unexpected argument %q", ws[0].text)
+	}
+	return cmd.Parse(ctx, c)
+}
+
+func (cmd *Command) flag(name string) *Flag {
+	for i := range cmd.Flags {
+		if cmd.Flags[i].Name == name {
+			return &cmd.Flags[i]
+		}
+	}
+	return nil
+}
+
+// check checks the value v of the flag or argument name, which has type
+// t and, if choices is not empty, one of its values.
+func (c *CommandLine) check(ctx *Context, name string, t ArgType, choices []string, v *argValue) error {
+	if len(choices) > 0 && !contains(choices, v.text) {
+		return c.usageError(v.col, "bad %s %q: must be %s", name, v.text, strings.Join(choices, " or "))
+	}
+	switch t {
+	case IntArg:
+		if v.text == "_" {
+			return nil
+		}
+		if n, err := strconv.Atoi(v.text); err != nil || n <= 0 {
+			return c.usageError(v.col, "bad %s %q: must be a positive number or _", name, v.text)
+		}
+	case PathArg:
+		p, err := ctx.resolve(c.File, v.text)
+		if err != nil {
+			return &ParseError{File: c.File, Line: c.Line, Column: v.col, Code: CodeReadFile, Msg: err.Error(), Err: err}
+		}
+		v.path = p
+	case URLArg:
+		u, err := url.Parse(v.text)
+		if err != nil {
+			return &ParseError{File: c.File, Line: c.Line, Column: v.col, Code: CodeBadURL, Msg: err.Error(), Err: err}
+		}
+		switch {
+		case u.Scheme == "" && u.Host == "":
+			p, err := ctx.resolve(c.File, u.Path)
+			if err != nil {
+				return &ParseError{File: c.File, Line: c.Line, Column: v.col, Code: CodeBadURL, Msg: err.Error(), Err: err}
+			}
+			v.path = p
+		case u.Scheme != "http" && u.Scheme != "https":
+			return errorf(c.File, c.Line, v.col, CodeBadURL, "bad %s %q: must be http, https or a relative path", name, v.text)
+		}
+	}
+	return nil
+}
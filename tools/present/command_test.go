@@ -0,0 +1,78 @@
+package present
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCommandUsage(t *testing.T) {
+	for name, want := range map[string]string{
+		"code":    ".code [-numbers] [-edit] file [address...]",
+		"iframe":  ".iframe url [width height]",
+		"diagram": ".diagram [TB|LR]",
+		"image":   ".image file [height width]",
+		"link":    ".link url [label...]",
+	} {
+		if got := commands[name].Usage(); got != want {
+			t.Errorf("%s: got usage %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestCommandErrors(t *testing.T) {
+	for _, tt := range []struct {
+		in  string
+		col int
+		msg string
+	}{
+		{".code -lines hello.go", 7, "unknown flag -lines"},
+		{".code -numbers", 15, "missing file"},
+		{".iframe https://go.dev/ 400", 28, "missing height"},
+		{".iframe https://go.dev/ 400 wide", 29, `bad height "wide"`},
+		{".iframe https://go.dev/ 400 300 more", 33, `unexpected argument "more"`},
+		{".video  gopher.mp4 mp4", 20, `bad video type "mp4"`},
+		{".diagram RL\n\ta -> b", 10, `bad direction "RL"`},
+		{".code hello.go /nomatch/", 16, "no match"},
+		{".image gopher.png 100 200 300", 27, `unexpected argument "300"`},
+		{".link", 6, "missing url"},
+	} {
+		_, err := testContext().Parse(strings.NewReader("Title\n\n* S\n\n"+tt.in+"\n"), "doc.slide", 0)
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("%s: got %v, want a ParseError", tt.in, err)
+			continue
+		}
+		if perr.Column != tt.col || !strings.Contains(perr.Msg, tt.msg) {
+			t.Errorf("%s: got %v, want column %d and %q", tt.in, err, tt.col, tt.msg)
+		}
+	}
+}
+
+func TestCommandLine(t *testing.T) {
+	var got *CommandLine
+	cmd := &Command{
+		Name: "test",
+		Flags: []Flag{
+			{Name: "n", Type: IntArg},
+			{Name: "v", Type: BoolArg},
+		},
+		Args: []Arg{
+			{Name: "file", Type: PathArg},
+			{Name: "rest", Type: TextArg, Optional: true},
+		},
+		Parse: func(ctx *Context, c *CommandLine) (Elem, error) {
+			got = c
+			return Text{}, nil
+		},
+	}
+	if _, err := cmd.parse(testContext(), "dir/doc.slide", 3, ".test -v -n 2 a.go  the  rest ", nil); err != nil {
+		t.Fatal(err)
+	}
+	if !got.Flag("v") || got.Int("n") != 2 || got.Path("file") != "dir/a.go" || got.String("rest") != "the  rest" || got.Cmd != ".test -v -n 2 a.go  the  rest" {
+		t.Errorf("got %+v", got)
+	}
+	if _, err := cmd.parse(testContext(), "doc.slide", 3, ".test -n", nil); err == nil || !strings.Contains(err.Error(), "flag -n needs a value") {
+		t.Errorf("flag without a value: got %v", err)
+	}
+}
@@ -0,0 +1,335 @@
+package present
+
+import (
+	"fmt"
+	"hash/fnv"
+	"html"
+	"html/template"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+func init() {
+	RegisterCommand(&Command{
+		Name:  "diagram",
+		Doc:   "Draws the graph whose edges are in the indented block below.",
+		Block: true,
+		Args:  []Arg{{Name: "direction", Optional: true, Choices: []string{"TB", "LR"}, Doc: "top to bottom, the default, or left to right"}},
+		Parse: parseDiagram,
+	})
+}
+
+/*
+	A diagram is a graph described in the indented block below .diagram,
+	one chain of edges per line:
+
+		.diagram LR
+			source -> lexer -> parser -> AST
+			parser -> errors: syntax error
+			AST -- types
+
+	"->" draws an arrow and "--" a plain line; text after the last colon
+	of a line labels its edges. A line holding only a name adds a node.
+	The nodes are laid out in ranks from top to bottom, or from left to
+	right with LR, and rendered as inline SVG.
+*/
+
+// Diagram is a graph drawn as SVG.
+type Diagram struct {
+	Cmd  string   // original command from present source
+	Body []string // indented lines following the command
+	SVG  template.HTML
+}
+
+func (d Diagram) TemplateName() string { return "diagram" }
+
+type diagramEdge struct {
+	from, to int
+	directed bool
+	label    string
+}
+
+type diagramNode struct {
+	name    string
+	rank    int
+	x, y, w int // centre and width
+}
+
+// Sizes of the diagram's parts, in pixels.
+const (
+	diagramCharWidth  = 8
+	diagramNodeHeight = 32
+	diagramNodePad    = 12
+	diagramRankGap    = 60
+	diagramNodeGap    = 24
+	diagramMargin     = 8
+)
+
+func parseDiagram(ctx *Context, c *CommandLine) (Elem, error) {
+	body := c.Body
+	horizontal := c.String("direction") == "LR"
+	if len(body) == 0 {
+		return nil, c.Errorf("", ".diagram needs an indented block of edges")
+	}
+
+	var nodes []*diagramNode
+	index := make(map[string]int)
+	node := func(name string) int {
+		i, ok := index[name]
+		if !ok {
+			i = len(nodes)
+			index[name] = i
+			nodes = append(nodes, &diagramNode{name: name})
+		}
+		return i
+	}
+	var edges []diagramEdge
+	for i, line := range body {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		label := ""
+		if j := strings.LastIndex(line, ":"); j >= 0 {
+			line, label = strings.TrimSpace(line[:j]), strings.TrimSpace(line[j+1:])
+		}
+		names, directed := splitChain(line)
+		for j, name := range names {
+			if name == "" {
+				return nil, errorf(c.File, c.Line+1+i, valueColumn(body[i], ""), CodeBadCommand, "diagram: missing node name in %q", body[i])
+			}
+			n := node(name)
+			if j > 0 {
+				edges = append(edges, diagramEdge{from: index[names[j-1]], to: n, directed: directed[j-1], label: label})
+			}
+		}
+		if len(names) == 1 && label != "" {
+			return nil, errorf(c.File, c.Line+1+i, valueColumn(body[i], ""), CodeBadCommand, "diagram: label without an edge in %q", body[i])
+		}
+	}
+
+	layoutDiagram(nodes, edges, horizontal)
+	h := fnv.New32a()
+	h.Write([]byte(strings.Join(body, "\n")))
+	svg := diagramSVG(nodes, edges, fmt.Sprintf("arrow-%08x", h.Sum32()))
+	return Diagram{Cmd: c.Cmd, Body: body, SVG: template.HTML(svg)}, nil
+}
+
+// splitChain splits a line such as "a -> b -- c" into its node names and
+// reports for each edge between them whether it is directed.
+func splitChain(line string) (names []string, directed []bool) {
+	for {
+		i := strings.Index(line, "->")
+		j := strings.Index(line, "--")
+		if i < 0 && j < 0 {
+			return append(names, strings.TrimSpace(line)), directed
+		}
+		if i < 0 || j >= 0 && j < i {
+			i = j
+		}
+		names = append(names, strings.TrimSpace(line[:i]))
+		directed = append(directed, line[i+1] == '>')
+		line = line[i+2:]
+	}
+}
+
+// layoutDiagram places the nodes. Each node is ranked one below the
+// highest-ranked node with an edge to it, ignoring edges that close a
+// cycle; nodes of the same rank keep the order they first appeared in.
+func layoutDiagram(nodes []*diagramNode, edges []diagramEdge, horizontal bool) {
+	// Find the edges that close a cycle with a depth-first search.
+	out := make([][]int, len(nodes))
+	for i, e := range edges {
+		out[e.from] = append(out[e.from], i)
+	}
+	back := make([]bool, len(edges))
+	state := make([]int, len(nodes)) // 0 unvisited, 1 on stack, 2 done
+	var visit func(n int)
+	visit = func(n int) {
+		state[n] = 1
+		for _, i := range out[n] {
+			switch to := edges[i].to; state[to] {
+			case 0:
+				visit(to)
+			case 1:
+				back[i] = true
+			}
+		}
+		state[n] = 2
+	}
+	for n := range nodes {
+		if state[n] == 0 {
+			visit(n)
+		}
+	}
+	// Longest path ranking; at most len(nodes) rounds are needed.
+	for changed, round := true, 0; changed && round < len(nodes); round++ {
+		changed = false
+		for i, e := range edges {
+			if !back[i] && e.from != e.to && nodes[e.to].rank < nodes[e.from].rank+1 {
+				nodes[e.to].rank = nodes[e.from].rank + 1
+				changed = true
+			}
+		}
+	}
+
+	var ranks [][]*diagramNode
+	for _, n := range nodes {
+		n.w = textWidth(n.name)*diagramCharWidth + 2*diagramNodePad
+		for len(ranks) <= n.rank {
+			ranks = append(ranks, nil)
+		}
+		ranks[n.rank] = append(ranks[n.rank], n)
+	}
+
+	// Along a rank, nodes are spaced by their size: widths when the
+	// ranks are rows, heights when they are columns. Across ranks, the
+	// rank is as thick as its widest node when they are columns.
+	span := func(n *diagramNode) int {
+		if horizontal {
+			return diagramNodeHeight
+		}
+		return n.w
+	}
+	length := make([]int, len(ranks))
+	longest := 0
+	for r, rank := range ranks {
+		for i, n := range rank {
+			if i > 0 {
+				length[r] += diagramNodeGap
+			}
+			length[r] += span(n)
+		}
+		if length[r] > longest {
+			longest = length[r]
+		}
+	}
+	across := diagramMargin
+	for r, rank := range ranks {
+		thick := diagramNodeHeight
+		if horizontal {
+			thick = 0
+			for _, n := range rank {
+				if n.w > thick {
+					thick = n.w
+				}
+			}
+		}
+		along := diagramMargin + (longest-length[r])/2
+		for _, n := range rank {
+			s := span(n)
+			if horizontal {
+				n.x, n.y = across+thick/2, along+s/2
+			} else {
+				n.x, n.y = along+s/2, across+thick/2
+			}
+			along += s + diagramNodeGap
+		}
+		across += thick + diagramRankGap
+	}
+}
+
+// textWidth returns the width of s in columns, counting wide characters
+// as two.
+func textWidth(s string) int {
+	n := 0
+	for _, r := range s {
+		n++
+		if utf8.RuneLen(r) >= 3 {
+			n++
+		}
+	}
+	return n
+}
+
+// diagramSVG draws the laid out diagram. marker is the id of the arrow
+// head, which must be unique in the page. Edges between neighbouring
+// ranks are straight; the others, which could cross the nodes between
+// their ends, bow out to the side.
+func diagramSVG(nodes []*diagramNode, edges []diagramEdge, marker string) string {
+	type path struct {
+		d              string
+		labelX, labelY int
+	}
+	width, height := 0, 0
+	grow := func(x, y int) {
+		if x+diagramMargin > width {
+			width = x + diagramMargin
+		}
+		if y+diagramMargin > height {
+			height = y + diagramMargin
+		}
+	}
+	paths := make([]path, len(edges))
+	for i, e := range edges {
+		from, to := nodes[e.from], nodes[e.to]
+		if from == to {
+			continue
+		}
+		if to.rank-from.rank == 1 {
+			x1, y1 := boxEdge(from, to.x, to.y)
+			x2, y2 := boxEdge(to, from.x, from.y)
+			paths[i] = path{fmt.Sprintf("M%d,%d L%d,%d", x1, y1, x2, y2), (x1 + x2) / 2, (y1 + y2) / 2}
+			continue
+		}
+		// Put the control point of a quadratic curve one rank gap away
+		// from the middle of the line between the centres, to its right
+		// or below it so that the curve stays inside the picture.
+		dx, dy := float64(to.x-from.x), float64(to.y-from.y)
+		l := math.Hypot(dx, dy)
+		px, py := -dy/l, dx/l
+		if px < 0 || px == 0 && py < 0 {
+			px, py = -px, -py
+		}
+		cx := (from.x+to.x)/2 + int(px*diagramRankGap)
+		cy := (from.y+to.y)/2 + int(py*diagramRankGap)
+		x1, y1 := boxEdge(from, cx, cy)
+		x2, y2 := boxEdge(to, cx, cy)
+		mx, my := (x1+2*cx+x2)/4, (y1+2*cy+y2)/4
+		grow(mx, my)
+		paths[i] = path{fmt.Sprintf("M%d,%d Q%d,%d %d,%d", x1, y1, cx, cy, x2, y2), mx, my}
+	}
+	for _, n := range nodes {
+		grow(n.x+n.w/2, n.y+diagramNodeHeight/2)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="14">`, width, height, width, height)
+	fmt.Fprintf(&b, `<defs><marker id="%s" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="8" markerHeight="8" orient="auto"><path d="M0,0 L10,5 L0,10 z"/></marker></defs>`, marker)
+	for i, e := range edges {
+		p := paths[i]
+		if p.d == "" {
+			continue
+		}
+		fmt.Fprintf(&b, `<path d="%s" fill="none" stroke="black"`, p.d)
+		if e.directed {
+			fmt.Fprintf(&b, ` marker-end="url(#%s)"`, marker)
+		}
+		b.WriteString("/>")
+		if e.label != "" {
+			fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="middle" dy="-4" font-size="12">%s</text>`, p.labelX, p.labelY, html.EscapeString(e.label))
+		}
+	}
+	for _, n := range nodes {
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" rx="4" fill="white" stroke="black"/>`, n.x-n.w/2, n.y-diagramNodeHeight/2, n.w, diagramNodeHeight)
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="middle" dominant-baseline="central">%s</text>`, n.x, n.y, html.EscapeString(n.name))
+	}
+	b.WriteString("</svg>")
+	return b.String()
+}
+
+// boxEdge returns the point where the line from the centre of n towards
+// (x, y) leaves n's box.
+func boxEdge(n *diagramNode, x, y int) (int, int) {
+	dx, dy := float64(x-n.x), float64(y-n.y)
+	hw, hh := float64(n.w)/2, float64(diagramNodeHeight)/2
+	t := 1.0
+	if dx != 0 && hw/math.Abs(dx) < t {
+		t = hw / math.Abs(dx)
+	}
+	if dy != 0 && hh/math.Abs(dy) < t {
+		t = hh / math.Abs(dy)
+	}
+	return n.x + int(dx*t), n.y + int(dy*t)
+}
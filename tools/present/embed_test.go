@@ -0,0 +1,69 @@
+package present
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestEmbeds(t *testing.T) {
+	const src = `Title
+
+* Embeds
+
+.html fragment.html
+
+.iframe https://go.dev/play/ 600 _
+
+.video gopher.mp4 video/mp4 640 360
+
+.caption A *gopher* in motion.
+`
+	doc, err := testContext().Parse(strings.NewReader(src), "doc.slide", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	elems := doc.Sections[0].Elem
+	const want = `<p>Hi <a>there</a><em>unclosed</em></p>`
+	if got := string(elems[0].(HTML).HTML); got != want {
+		t.Errorf("got HTML %s\nwant %s", got, want)
+	}
+	if i := elems[1].(Iframe); i.Width != 600 || i.Height != 0 {
+		t.Errorf("got iframe %+v", i)
+	}
+	if v := elems[2].(Video); v.SourceType != "video/mp4" || v.Height != 360 || v.Width != 640 {
+		t.Errorf("got video %+v", v)
+	}
+	if c := elems[3].(Caption); c.Text != "A *gopher* in motion." {
+		t.Errorf("got caption %+v", c)
+	}
+	checkRoundTrip(t, testContext(), "doc.slide", doc)
+}
+
+func TestEmbedErrors(t *testing.T) {
+	for _, tt := range []struct{ in, code string }{
+		{".iframe https://go.dev/ 400", CodeBadCommand},
+		{".iframe https://go.dev/ 0 600", CodeBadCommand},
+		{".iframe https://go.dev/ tall 600", CodeBadCommand},
+		{".iframe javascript:alert(1)", CodeBadURL},
+		{".video gopher.mp4 mp4", CodeBadCommand},
+		{".video gopher.mp4", CodeBadCommand},
+		{".video ../gopher.mp4 video/mp4", CodeBadURL},
+		{".html ../page.html", CodeReadFile},
+		{".html missing.html", CodeReadFile},
+		{".caption", CodeBadCommand},
+		{".link javascript:alert(1) click", CodeBadURL},
+	} {
+		ctx := &Context{FS: fstest.MapFS{"doc.slide": {}}}
+		_, err := ctx.Parse(strings.NewReader("Title\n\n* S\n\n"+tt.in+"\n"), "doc.slide", 0)
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("%s: got %v, want a ParseError", tt.in, err)
+			continue
+		}
+		if perr.Code != tt.code {
+			t.Errorf("%s: got code %s, want %s (%v)", tt.in, perr.Code, tt.code, err)
+		}
+	}
+}
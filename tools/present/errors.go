@@ -0,0 +1,100 @@
+package present
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Error codes carried by a ParseError, so tools can tell problems apart
+// without matching messages.
+const (
+	CodeUnexpectedEOF  = "unexpected-eof"  // the input ended too early
+	CodeBadHeader      = "bad-header"      // a header line could not be understood
+	CodeBadURL         = "bad-url"         // an author line holds an invalid URL
+	CodeUnknownCommand = "unknown-command" // a line starts with an unregistered command
+	CodeBadCommand     = "bad-command"     // a command's arguments are invalid
+	CodeReadFile       = "read-file"       // a file referenced by a command could not be read
+	CodeIncludeCycle   = "include-cycle"   // a file includes itself, directly or not
+)
+
+// A ParseError describes a problem found at a position in a present file.
+type ParseError struct {
+	File   string
+	Line   int // 1-based line number; 0 if unknown
+	Column int // 1-based column in bytes; 0 if unknown or the whole line is at fault
+	Code   string
+	Msg    string
+	Err    error // underlying error, if any
+}
+
+func (e *ParseError) Error() string {
+	pos := e.File
+	if e.Line > 0 {
+		pos += fmt.Sprintf(":%d", e.Line)
+		if e.Column > 0 {
+			pos += fmt.Sprintf(":%d", e.Column)
+		}
+	}
+	if pos == "" {
+		return e.Msg
+	}
+	return pos + ": " + e.Msg
+}
+
+// Unwrap returns the underlying error.
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// errorf returns a ParseError for column col of a line.
+func errorf(file string, line, col int, code, format string, args ...interface{}) *ParseError {
+	return &ParseError{File: file, Line: line, Column: col, Code: code, Msg: fmt.Sprintf(format, args...)}
+}
+
+// An ErrorList is a list of ParseErrors. The zero value is an empty list
+// ready to use.
+type ErrorList []*ParseError
+
+// Add appends e to the list.
+func (l *ErrorList) Add(e *ParseError) { *l = append(*l, e) }
+
+// Sort sorts the list by file, line and column.
+func (l ErrorList) Sort() {
+	sort.SliceStable(l, func(i, j int) bool {
+		a, b := l[i], l[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+}
+
+// Error implements the error interface.
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+// Err returns an error equivalent to this list, or nil if it is empty.
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
+
+// Unwrap returns the errors in the list, so that errors.Is and errors.As
+// look at each of them.
+func (l ErrorList) Unwrap() []error {
+	errs := make([]error, len(l))
+	for i, e := range l {
+		errs[i] = e
+	}
+	return errs
+}
@@ -0,0 +1,105 @@
+package present
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+func TestParseErrorString(t *testing.T) {
+	for _, tt := range []struct {
+		err  ParseError
+		want string
+	}{
+		{ParseError{File: "a.slide", Line: 3, Column: 7, Msg: "bad"}, "a.slide:3:7: bad"},
+		{ParseError{File: "a.slide", Line: 3, Msg: "bad"}, "a.slide:3: bad"},
+		{ParseError{File: "a.slide", Msg: "bad"}, "a.slide: bad"},
+		{ParseError{Msg: "bad"}, "bad"},
+	} {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestErrorList(t *testing.T) {
+	var l ErrorList
+	if l.Err() != nil {
+		t.Errorf("empty list: got Err %v, want nil", l.Err())
+	}
+	if got := l.Error(); got != "no errors" {
+		t.Errorf("empty list: got %q", got)
+	}
+
+	l.Add(errorf("b.slide", 1, 0, CodeBadHeader, "b1"))
+	if got, want := l.Err().Error(), "b.slide:1: b1"; got != want {
+		t.Errorf("one error: got %q, want %q", got, want)
+	}
+	l.Add(errorf("a.slide", 9, 0, CodeBadHeader, "a9"))
+	l.Add(errorf("a.slide", 2, 5, CodeBadCommand, "a2.5"))
+	l.Add(errorf("a.slide", 2, 0, CodeBadCommand, "a2"))
+	l.Add(errorf("a.slide", 2, 5, CodeBadCommand, "a2.5 again"))
+	l.Sort()
+	var got []string
+	for _, e := range l {
+		got = append(got, e.Msg)
+	}
+	if want := "a2 a2.5 a2.5 again a9 b1"; strings.Join(got, " ") != want {
+		t.Errorf("sorted: got %q, want %q", strings.Join(got, " "), want)
+	}
+	if got, want := l.Error(), "a.slide:2: a2 (and 4 more errors)"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// errors.As and errors.Is see each error of the list.
+	l.Add(&ParseError{File: "c.slide", Line: 1, Code: CodeReadFile, Msg: "missing", Err: fs.ErrNotExist})
+	err := l.Err()
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Error("errors.Is doesn't find the error wrapped by a ParseError")
+	}
+	var perr *ParseError
+	if !errors.As(err, &perr) || perr.Msg != "a2" {
+		t.Errorf("errors.As: got %v, want the first error", perr)
+	}
+	if n := len(l.Unwrap()); n != len(l) {
+		t.Errorf("Unwrap returned %d errors, want %d", n, len(l))
+	}
+}
+
+func TestErrorColumns(t *testing.T) {
+	for _, tt := range []struct {
+		name, in string
+		line     int
+		col      int
+		code     string
+	}{
+		{"order header", "Title\nOrder:  first\n\n* S\n", 2, 9, CodeBadHeader},
+		{"draft header", "Title\nDraft: maybe\n\n* S\n", 2, 8, CodeBadHeader},
+		{"unexpected header line", "Title\nSubtitle\nMore\n\n* S\n", 3, 0, CodeBadHeader},
+		{"author URL", "Title\n\nGopher\nHomepage: http://%zz\n\n* S\n", 4, 11, CodeBadURL},
+		{"unknown command", "Title\n\n* S\n\n.nope x\n", 5, 0, CodeUnknownCommand},
+		{"code file", "Title\n\n* S\n\n.code -edit missing.go\n", 5, 13, CodeReadFile},
+		{"code highlight", "Title\n\n* S\n\n.code hello.go HL\n", 5, 16, CodeBadCommand},
+		{"include without file", "Title\n\n* S\n\n.include\n", 5, 9, CodeBadCommand},
+		{"background with two images", "Title\n\n* S\n\n.background a.png b.png\n", 5, 19, CodeBadCommand},
+		{"background without image", "Title\n\n* S\n\n.background\n", 5, 12, CodeBadCommand},
+		{"missing include", "Title\n\n* S\n\n.include  missing.slide\n", 5, 11, CodeReadFile},
+		{"image argument", "Title\n\n* S\n\n.image hello.go 100 x\n", 5, 21, CodeBadCommand},
+		{"image sizes", "Title\n\n* S\n\n.image hello.go 100\n", 5, 20, CodeBadCommand},
+		{"link URL", "Title\n\n* S\n\n.link http://%zz x\n", 5, 7, CodeBadCommand},
+		{"link scheme", "Title\n\n* S\n\n.link javascript:alert(1) x\n", 5, 7, CodeBadURL},
+		{"diagram line", "Title\n\n* S\n\n.diagram\n\ta -> b\n\t  c: no edge\n", 7, 4, CodeBadCommand},
+		{"quiz line", "Title\n\n* S\n\n.quiz\n\tQuestion: Q?\n\ta) 1\n\tb) 2\n\n\t  nonsense\n", 10, 4, CodeBadCommand},
+	} {
+		_, err := Parse(strings.NewReader(tt.in), "doc.slide", 0)
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("%s: got %v, want a ParseError", tt.name, err)
+			continue
+		}
+		if perr.Line != tt.line || perr.Column != tt.col || perr.Code != tt.code {
+			t.Errorf("%s: got %v (%s), want line %d, column %d, %s", tt.name, perr, perr.Code, tt.line, tt.col, tt.code)
+		}
+	}
+}
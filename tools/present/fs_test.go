@@ -0,0 +1,41 @@
+package present
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestContextFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"root/talks/go/talk.slide": {Data: []byte("Talk\n\n* Code\n\n.code hello.go\n\n.code ../shared/bye.go\n\n.image /img/gopher.png\n")},
+		"root/talks/go/hello.go":   {Data: []byte(testFiles["hello.go"])},
+		"root/talks/shared/bye.go": {Data: []byte("package bye\n")},
+		"root/img/gopher.png":      {Data: []byte("png")},
+		"secret.go":                {Data: []byte("package secret\n")},
+	}
+	ctx := &Context{FS: fsys, Dir: "root"}
+	src, _ := fsys.ReadFile("root/talks/go/talk.slide")
+	doc, err := ctx.Parse(strings.NewReader(string(src)), "talks/go/talk.slide", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	elems := doc.Sections[0].Elem
+	if len(elems) != 3 {
+		t.Fatalf("got %d elements, want 3", len(elems))
+	}
+	if c := elems[1].(Code); string(c.Raw) != "package bye\n" {
+		t.Errorf("../shared/bye.go: got %q", c.Raw)
+	}
+	if errs := ctx.Lint(strings.NewReader(string(src)), "talks/go/talk.slide", LintOptions{}); len(errs) > 0 {
+		t.Errorf("lint: %v", errs)
+	}
+
+	for _, cmd := range []string{".code ../../../secret.go", ".code /../secret.go", ".image ../../../secret.go"} {
+		_, err := ctx.Parse(strings.NewReader("Talk\n\n* Escape\n\n"+cmd+"\n"), "talks/go/talk.slide", 0)
+		if !errors.Is(err, ErrOutsideRoot) {
+			t.Errorf("%s: got error %v, want ErrOutsideRoot", cmd, err)
+		}
+	}
+}
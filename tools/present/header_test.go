@@ -0,0 +1,37 @@
+package present
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		subtitle string
+		meta     map[string]string
+	}{
+		{"subtitle", "A tour", "A tour", nil},
+		{"subtitle with colon", "Concurrency: a tour", "Concurrency: a tour", nil},
+		{"meta", "Meta-Slug: whats-new", "", map[string]string{"Slug": "whats-new"}},
+		{"empty meta", "Meta-Slug:", "", map[string]string{"Slug": ""}},
+		{"meta and subtitle", "Meta-Slug: x\nConcurrency: a tour\nMeta-Owner: gophers", "Concurrency: a tour",
+			map[string]string{"Slug": "x", "Owner": "gophers"}},
+	}
+	for _, tt := range tests {
+		src := "Title\n" + tt.header + "\n\n* S\n\nText.\n"
+		doc, err := testContext().Parse(strings.NewReader(src), "doc.slide", 0)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if doc.Subtitle != tt.subtitle {
+			t.Errorf("%s: subtitle %q, want %q", tt.name, doc.Subtitle, tt.subtitle)
+		}
+		if !reflect.DeepEqual(doc.Meta, tt.meta) {
+			t.Errorf("%s: meta %v, want %v", tt.name, doc.Meta, tt.meta)
+		}
+	}
+}
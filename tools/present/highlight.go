@@ -0,0 +1,121 @@
+package present
+
+import (
+	"go/scanner"
+	"go/token"
+	"html"
+	"html/template"
+	"strings"
+)
+
+// Go code is highlighted by wrapping its tokens in spans whose class
+// names their kind: go-comment, go-string, go-number, go-keyword and
+// go-builtin for the predeclared identifiers. Code in other languages is
+// shown as it is.
+
+// highlightLines sets the H field of each line to the HTML for its text
+// after the leading space. lang is the language of the code, such as
+// "go", or "" if it is not known.
+func highlightLines(lines []codeLine, lang string) {
+	var src strings.Builder
+	for i, l := range lines {
+		if i > 0 {
+			src.WriteByte('\n')
+		}
+		src.WriteString(l.L)
+	}
+	text := src.String()
+	var spans []tokenSpan
+	if lang == "go" {
+		spans = goTokens(text)
+	}
+	off := 0
+	for i := range lines {
+		l := lines[i].L
+		start := off + len(leadingSpaceRE.FindString(l))
+		end := off + len(strings.TrimRight(l, " \t"))
+		var b strings.Builder
+		for start < end {
+			for len(spans) > 0 && spans[0].end <= start {
+				spans = spans[1:]
+			}
+			if len(spans) == 0 || spans[0].start >= end {
+				b.WriteString(html.EscapeString(text[start:end]))
+				break
+			}
+			s := spans[0]
+			if s.start > start {
+				b.WriteString(html.EscapeString(text[start:s.start]))
+				start = s.start
+			}
+			stop := s.end
+			if stop > end {
+				stop = end
+			}
+			b.WriteString(`<span class="` + s.class + `">`)
+			b.WriteString(html.EscapeString(text[start:stop]))
+			b.WriteString("</span>")
+			start = stop
+		}
+		lines[i].H = template.HTML(b.String())
+		off += len(l) + 1
+	}
+}
+
+// tokenSpan is the byte range of a token to be highlighted.
+type tokenSpan struct {
+	start, end int
+	class      string
+}
+
+// goTokens returns the tokens of the Go code src to be highlighted, in
+// order. The code need not be a whole file, and errors in it are ignored.
+func goTokens(src string) []tokenSpan {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var s scanner.Scanner
+	s.Init(file, []byte(src), nil, scanner.ScanComments)
+	var spans []tokenSpan
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		class := ""
+		switch {
+		case tok == token.COMMENT:
+			class = "go-comment"
+		case tok == token.STRING || tok == token.CHAR:
+			class = "go-string"
+		case tok == token.INT || tok == token.FLOAT || tok == token.IMAG:
+			class = "go-number"
+		case tok.IsKeyword():
+			class = "go-keyword"
+			lit = tok.String()
+		case tok == token.IDENT && predeclared[lit]:
+			class = "go-builtin"
+		default:
+			continue
+		}
+		start := file.Offset(pos)
+		spans = append(spans, tokenSpan{start, start + len(lit), class})
+	}
+	return spans
+}
+
+var predeclared = map[string]bool{
+	"any": true, "bool": true, "byte": true, "comparable": true,
+	"complex64": true, "complex128": true, "error": true, "float32": true,
+	"float64": true, "int": true, "int8": true, "int16": true,
+	"int32": true, "int64": true, "rune": true, "string": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true,
+	"uint64": true, "uintptr": true,
+
+	"true": true, "false": true, "iota": true, "nil": true,
+
+	"append": true, "cap": true, "clear": true, "close": true,
+	"complex": true, "copy": true, "delete": true, "imag": true,
+	"len": true, "make": true, "max": true, "min": true, "new": true,
+	"panic": true, "print": true, "println": true, "real": true,
+	"recover": true,
+}
@@ -0,0 +1,38 @@
+package present
+
+import (
+	"html/template"
+	"reflect"
+	"testing"
+)
+
+func TestHighlightLines(t *testing.T) {
+	lines := []codeLine{
+		{L: "func f() int {"},
+		{L: "    s := `a<b"},
+		{L: "    c` // HL"},
+		{L: "    return len(s) + 1 // count"},
+		{L: "}"},
+	}
+	highlightLines(lines, "go")
+	want := []template.HTML{
+		`<span class="go-keyword">func</span> f() <span class="go-builtin">int</span> {`,
+		"s := <span class=\"go-string\">`a&lt;b</span>",
+		"<span class=\"go-string\">c`</span> <span class=\"go-comment\">// HL</span>",
+		`<span class="go-keyword">return</span> <span class="go-builtin">len</span>(s) + <span class="go-number">1</span> <span class="go-comment">// count</span>`,
+		`}`,
+	}
+	var got []template.HTML
+	for _, l := range lines {
+		got = append(got, l.H)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n%q\nwant\n%q", got, want)
+	}
+
+	lines = []codeLine{{L: "  if a < b {"}}
+	highlightLines(lines, "c")
+	if want := template.HTML("if a &lt; b {"); lines[0].H != want {
+		t.Errorf("C: got %q, want %q", lines[0].H, want)
+	}
+}
@@ -0,0 +1,148 @@
+package present
+
+import (
+	"bytes"
+	"html/template"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+func init() {
+	RegisterCommand(&Command{
+		Name:  "html",
+		Doc:   "Includes a fragment of HTML, with anything that could run code removed.",
+		Args:  []Arg{{Name: "file", Type: PathArg, Doc: "the fragment"}},
+		Parse: parseHTML,
+	})
+}
+
+// HTML is a fragment of HTML read from a file by
+//
+//	.html fragment.html
+//
+// The fragment is sanitised: elements and attributes not in the lists
+// below are dropped, along with the content of script-like elements, and
+// URLs must be http, https, mailto or relative.
+type HTML struct {
+	Cmd string // original command from present source
+	template.HTML
+}
+
+func (s HTML) TemplateName() string { return "html" }
+
+func parseHTML(ctx *Context, c *CommandLine) (Elem, error) {
+	b, err := c.ReadFile(ctx, "file")
+	if err != nil {
+		return nil, err
+	}
+	clean, err := sanitizeHTML(b)
+	if err != nil {
+		return nil, c.Errorf("file", "%v", err)
+	}
+	return HTML{Cmd: c.Cmd, HTML: template.HTML(clean)}, nil
+}
+
+// allowedElements are the elements kept by sanitizeHTML, with the
+// attributes they may have besides the ones in allowedAttrs.
+var allowedElements = map[atom.Atom][]string{
+	atom.A: {"href", "title"}, atom.Abbr: {"title"}, atom.B: nil,
+	atom.Blockquote: nil, atom.Br: nil, atom.Caption: nil, atom.Code: nil,
+	atom.Dd: nil, atom.Del: nil, atom.Details: nil, atom.Div: nil,
+	atom.Dl: nil, atom.Dt: nil, atom.Em: nil, atom.Figcaption: nil,
+	atom.Figure: nil, atom.H1: nil, atom.H2: nil, atom.H3: nil,
+	atom.H4: nil, atom.Hr: nil, atom.I: nil, atom.Img: {"src", "alt", "width", "height"},
+	atom.Ins: nil, atom.Kbd: nil, atom.Li: nil, atom.Mark: nil,
+	atom.Ol: {"start"}, atom.P: nil, atom.Pre: nil, atom.Q: nil,
+	atom.S: nil, atom.Small: nil, atom.Span: nil, atom.Strong: nil,
+	atom.Sub: nil, atom.Summary: nil, atom.Sup: nil, atom.Table: nil,
+	atom.Tbody: nil, atom.Td: {"colspan", "rowspan"}, atom.Tfoot: nil,
+	atom.Th: {"colspan", "rowspan"}, atom.Thead: nil, atom.Tr: nil,
+	atom.U: nil, atom.Ul: nil,
+}
+
+// allowedAttrs are the attributes any allowed element may have.
+var allowedAttrs = map[string]bool{"class": true, "id": true, "lang": true, "dir": true}
+
+// droppedContent are the elements whose content is dropped with them,
+// rather than kept as the content of their parent.
+var droppedContent = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Iframe: true, atom.Object: true,
+	atom.Embed: true, atom.Template: true, atom.Noscript: true, atom.Title: true,
+	atom.Textarea: true, atom.Select: true,
+}
+
+// sanitizeHTML returns the HTML fragment b with everything not allowed
+// removed. The fragment is parsed as the content of a div, so unclosed
+// elements are closed and can't spill into the rest of the page.
+func sanitizeHTML(b []byte) (string, error) {
+	nodes, err := html.ParseFragment(bytes.NewReader(b), &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div})
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	for _, n := range nodes {
+		writeSanitized(&out, n)
+	}
+	return out.String(), nil
+}
+
+func writeSanitized(w *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		w.WriteString(html.EscapeString(n.Data))
+		return
+	case html.ElementNode:
+	default:
+		return // comments and doctypes
+	}
+	if droppedContent[n.DataAtom] {
+		return
+	}
+	attrs, ok := allowedElements[n.DataAtom]
+	if ok {
+		w.WriteString("<" + n.Data)
+		for _, a := range n.Attr {
+			if a.Namespace != "" || !allowedAttrs[a.Key] && !contains(attrs, a.Key) {
+				continue
+			}
+			if (a.Key == "href" || a.Key == "src") && !safeURL(a.Val) {
+				continue
+			}
+			w.WriteString(" " + a.Key + `="` + html.EscapeString(a.Val) + `"`)
+		}
+		w.WriteString(">")
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		writeSanitized(w, c)
+	}
+	if ok && !voidElements[n.DataAtom] {
+		w.WriteString("</" + n.Data + ">")
+	}
+}
+
+var voidElements = map[atom.Atom]bool{atom.Br: true, atom.Hr: true, atom.Img: true}
+
+func contains(list []string, s string) bool {
+	for _, t := range list {
+		if t == s {
+			return true
+		}
+	}
+	return false
+}
+
+// safeURL reports whether u can be followed without running code.
+func safeURL(u string) bool {
+	p, err := url.Parse(strings.TrimSpace(u))
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(p.Scheme) {
+	case "", "http", "https", "mailto":
+		return true
+	}
+	return false
+}
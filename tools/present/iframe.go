@@ -0,0 +1,33 @@
+package present
+
+func init() {
+	RegisterCommand(&Command{
+		Name: "iframe",
+		Doc:  "Embeds another page.",
+		Args: []Arg{
+			{Name: "url", Type: URLArg, Doc: "the page"},
+			{Name: "width", Type: IntArg, Optional: true, Doc: "the width in pixels"},
+			{Name: "height", Type: IntArg, Paired: true, Doc: "the height in pixels"},
+		},
+		Parse: parseIframe,
+	})
+}
+
+// Iframe embeds another page:
+//
+//	.iframe https://go.dev/play/ 600 400
+//
+// The width and height are optional, but come together; either may be
+// _ to leave it to the browser.
+type Iframe struct {
+	Cmd    string // original command from present source
+	URL    string
+	Width  int
+	Height int
+}
+
+func (i Iframe) TemplateName() string { return "iframe" }
+
+func parseIframe(ctx *Context, c *CommandLine) (Elem, error) {
+	return Iframe{Cmd: c.Cmd, URL: c.String("url"), Width: c.Int("width"), Height: c.Int("height")}, nil
+}
@@ -1,10 +1,20 @@
 package present
 
 func init() {
-	Register("image", parseImage)
+	RegisterCommand(&Command{
+		Name: "image",
+		Doc:  "Shows an image. Either size may be _ to keep the aspect ratio.",
+		Args: []Arg{
+			{Name: "file", Type: URLArg, Doc: "the image"},
+			{Name: "height", Type: IntArg, Optional: true, Doc: "the height in pixels"},
+			{Name: "width", Type: IntArg, Paired: true, Doc: "the width in pixels"},
+		},
+		Parse: parseImage,
+	})
 }
 
 type Image struct {
+	Cmd    string // original command from present source
 	URL    string
 	Width  int
 	Height int
@@ -12,7 +22,9 @@ type Image struct {
 
 func (i Image) TemplateName() string { return "image" }
 
-func parseImage(ctx *Context, fileName string, lineno int, text string) (Elem, error) {
-	var e Elem
-	return e, nil
-}
\ No newline at end of file
+func parseImage(ctx *Context, c *CommandLine) (Elem, error) {
+	// A size given as _ is left zero. The "image" action template will
+	// then omit that img tag attribute and the browser will calculate
+	// the value to preserve the aspect ratio.
+	return Image{Cmd: c.Cmd, URL: c.String("file"), Height: c.Int("height"), Width: c.Int("width")}, nil
+}
@@ -0,0 +1,119 @@
+package present
+
+import (
+	"bytes"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Include marks where an .include command spliced the sections of
+// another file into a section. The included sections follow it as
+// subsections of the section, whatever their level in their own file,
+// and keep the position they have there. Include renders as nothing.
+type Include struct {
+	Cmd  string // original command from present source
+	File string // the included file, as resolved; empty if it can't be
+}
+
+func (i Include) TemplateName() string { return "include" }
+
+// include handles the .include command cmd, found at the given line of
+// the document name, that includes the file ref into section.
+func (ctx *Context) include(name string, line int, cmd string, ref word, section *Section, errs *ErrorList) {
+	inc := Include{Cmd: cmd}
+	file, err := ctx.resolve(name, ref.text)
+	if err == nil {
+		inc.File = file
+	}
+	section.add(inc, Pos{name, line})
+	if err != nil {
+		errs.Add(&ParseError{File: name, Line: line, Column: ref.col, Code: CodeReadFile, Msg: err.Error(), Err: err})
+		return
+	}
+	for i, f := range ctx.including {
+		if filepath.Clean(f) == filepath.Clean(file) {
+			cycle := strings.Join(append(append([]string{}, ctx.including[i:]...), file), " -> ")
+			errs.Add(errorf(name, line, ref.col, CodeIncludeCycle, "include cycle: %s", cycle))
+			return
+		}
+	}
+	src, err := ctx.readFile(file)
+	if err != nil {
+		errs.Add(&ParseError{File: name, Line: line, Column: ref.col, Code: CodeReadFile, Msg: err.Error(), Err: err})
+		return
+	}
+	doc, err := ctx.Parse(bytes.NewReader(src), file, 0)
+	if err != nil {
+		addError(errs, err, file, 0)
+	}
+	if doc == nil {
+		return
+	}
+
+	// Relative image URLs in the included file are relative to it, but
+	// the sections are rendered as part of this document.
+	dir, err := filepath.Rel(filepath.Dir(name), filepath.Dir(file))
+	if err != nil {
+		dir = "."
+	}
+	for _, s := range doc.Sections {
+		rebase(&s, filepath.ToSlash(dir))
+		section.add(s, s.Pos)
+	}
+}
+
+// rebase makes the relative image and video URLs of s relative to the
+// parent directory dir.
+func rebase(s *Section, dir string) {
+	if dir == "." {
+		return
+	}
+	for i, e := range s.Elem {
+		switch e := e.(type) {
+		case Image:
+			e.URL = rebaseURL(dir, e.URL)
+			s.Elem[i] = e
+		case Video:
+			e.URL = rebaseURL(dir, e.URL)
+			s.Elem[i] = e
+		case Iframe:
+			e.URL = rebaseURL(dir, e.URL)
+			s.Elem[i] = e
+		case Section:
+			rebase(&e, dir)
+			s.Elem[i] = e
+		}
+	}
+	for i, style := range s.Styles {
+		const prefix, suffix = "background-image: url('", "')"
+		if strings.HasPrefix(style, prefix) && strings.HasSuffix(style, suffix) {
+			u := style[len(prefix) : len(style)-len(suffix)]
+			s.Styles[i] = prefix + rebaseURL(dir, u) + suffix
+		}
+	}
+}
+
+func rebaseURL(dir, u string) string {
+	p, err := url.Parse(u)
+	if err != nil || p.Scheme != "" || p.Host != "" || p.Path == "" || path.IsAbs(p.Path) {
+		return u
+	}
+	p.Path = path.Join(dir, p.Path)
+	return p.String()
+}
+
+// renumber sets the number of s and of its subsections, which are
+// numbered in their own file if an .include brought them in.
+func (s *Section) renumber(number []int) {
+	s.Number = number
+	n := 0
+	for i, e := range s.Elem {
+		if sub, ok := e.(Section); ok {
+			n++
+			sub.renumber(append(append([]int{}, number...), n))
+			s.Elem[i] = sub
+		}
+	}
+}
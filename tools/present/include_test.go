@@ -0,0 +1,86 @@
+package present
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const includeMain = `Training
+
+* Day one
+
+Welcome.
+
+.include shared/setup.article
+
+** Exercises
+
+Text.
+
+* Day two
+
+Text.
+`
+
+var includeFS = fstest.MapFS{
+	"shared/setup.article": {Data: []byte("Setup\n\n* Install\n\n.image gopher.png\n\n** Check\n\n.code hello.go\n\n* Editor\n\nText.\n")},
+	"shared/hello.go":      {Data: []byte(testFiles["hello.go"])},
+	"shared/gopher.png":    {Data: []byte("png")},
+	"a.article":            {Data: []byte("A\n\n* A\n\n.include b.article\n")},
+	"b.article":            {Data: []byte("B\n\n* B\n\n.include a.article\n")},
+}
+
+func TestInclude(t *testing.T) {
+	ctx := &Context{FS: includeFS}
+	doc, err := ctx.Parse(strings.NewReader(includeMain), "main.article", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := doc.Sections[0]
+	var got []string
+	for _, s := range day.Sections() {
+		got = append(got, s.FormattedNumber()+" "+s.Title+" "+s.Pos.String())
+		for _, sub := range s.Sections() {
+			got = append(got, sub.FormattedNumber()+" "+sub.Title+" "+sub.Pos.String())
+		}
+	}
+	want := []string{
+		"1.1. Install shared/setup.article:3",
+		"1.1.1. Check shared/setup.article:7",
+		"1.2. Editor shared/setup.article:11",
+		"1.3. Exercises main.article:9",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sections:\ngot  %q\nwant %q", got, want)
+	}
+	if img := day.Sections()[0].Elem[0].(Image); img.URL != "shared/gopher.png" {
+		t.Errorf("image URL %q, want shared/gopher.png", img.URL)
+	}
+	if pos := day.Sections()[0].ElemPos[0]; pos != (Pos{"shared/setup.article", 5}) {
+		t.Errorf("image at %v, want shared/setup.article:5", pos)
+	}
+
+	var buf bytes.Buffer
+	if err := Print(&buf, doc); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != includeMain {
+		t.Errorf("printed\n%s\nwant\n%s", buf.String(), includeMain)
+	}
+}
+
+func TestIncludeCycle(t *testing.T) {
+	ctx := &Context{FS: includeFS}
+	_, err := ctx.Parse(strings.NewReader("A\n\n* A\n\n.include b.article\n"), "a.article", 0)
+	var list ErrorList
+	if !errors.As(err, &list) || len(list) != 1 || list[0].Code != CodeIncludeCycle || list[0].File != "b.article" {
+		t.Fatalf("got %v, want an include cycle in b.article", err)
+	}
+	if want := "include cycle: a.article -> b.article -> a.article"; list[0].Msg != want {
+		t.Errorf("got %q, want %q", list[0].Msg, want)
+	}
+}
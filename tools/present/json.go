@@ -0,0 +1,175 @@
+package present
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+)
+
+// Docs are marshalled to JSON with the encoding/json conventions, the
+// fields of each struct under their Go names. An Elem is marshalled as
+// an object with its fields and a "Type" field holding its TemplateName,
+// which the unmarshaller uses to reconstruct the concrete type:
+//
+//	{"Type": "link", "Cmd": ".link https://go.dev Go", "URL": "https://go.dev", "Label": "Go"}
+//
+// URLs are marshalled as strings. Go programs have no need of an export
+// as Go source: they call Parse and use the Doc directly.
+
+// elemTypes maps the TemplateName of each Elem type to the type.
+var elemTypes = map[string]reflect.Type{}
+
+func init() {
+	for _, e := range []Elem{
+		Section{}, Text{}, List{}, Table{}, Code{}, Image{}, Link{},
+		Comment{}, Include{}, Math{}, Diagram{}, HTML{}, Iframe{}, Video{},
+		Caption{}, Quiz{},
+	} {
+		elemTypes[e.TemplateName()] = reflect.TypeOf(e)
+	}
+}
+
+func marshalElems(elems []Elem) ([]json.RawMessage, error) {
+	if elems == nil {
+		return nil, nil
+	}
+	out := make([]json.RawMessage, len(elems))
+	for i, e := range elems {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+		typ, err := json.Marshal(e.TemplateName())
+		if err != nil {
+			return nil, err
+		}
+		if len(b) < 2 || b[0] != '{' {
+			return nil, fmt.Errorf("present: %s element does not marshal to a JSON object", e.TemplateName())
+		}
+		var buf bytes.Buffer
+		buf.WriteString(`{"Type":`)
+		buf.Write(typ)
+		if len(bytes.TrimSpace(b[1:len(b)-1])) > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(b[1:])
+		out[i] = buf.Bytes()
+	}
+	return out, nil
+}
+
+func unmarshalElems(raw []json.RawMessage) ([]Elem, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	elems := make([]Elem, len(raw))
+	for i, r := range raw {
+		var head struct{ Type string }
+		if err := json.Unmarshal(r, &head); err != nil {
+			return nil, err
+		}
+		t, ok := elemTypes[head.Type]
+		if !ok {
+			return nil, fmt.Errorf("present: unknown element type %q", head.Type)
+		}
+		v := reflect.New(t)
+		if err := json.Unmarshal(r, v.Interface()); err != nil {
+			return nil, err
+		}
+		elems[i] = v.Elem().Interface().(Elem)
+	}
+	return elems, nil
+}
+
+// MarshalJSON marshals s with a type field on each of its elements.
+func (s Section) MarshalJSON() ([]byte, error) {
+	elems, err := marshalElems(s.Elem)
+	if err != nil {
+		return nil, err
+	}
+	type plain Section
+	return json.Marshal(struct {
+		plain
+		Elem []json.RawMessage
+	}{plain(s), elems})
+}
+
+// UnmarshalJSON unmarshals a Section marshalled by MarshalJSON.
+func (s *Section) UnmarshalJSON(b []byte) error {
+	type plain Section
+	var v struct {
+		*plain
+		Elem []json.RawMessage
+	}
+	v.plain = (*plain)(s)
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	elems, err := unmarshalElems(v.Elem)
+	s.Elem = elems
+	return err
+}
+
+// MarshalJSON marshals a with a type field on each of its elements.
+func (a Author) MarshalJSON() ([]byte, error) {
+	elems, err := marshalElems(a.Elem)
+	if err != nil {
+		return nil, err
+	}
+	type plain Author
+	return json.Marshal(struct {
+		plain
+		Elem []json.RawMessage
+	}{plain(a), elems})
+}
+
+// UnmarshalJSON unmarshals an Author marshalled by MarshalJSON.
+func (a *Author) UnmarshalJSON(b []byte) error {
+	type plain Author
+	var v struct {
+		*plain
+		Elem []json.RawMessage
+	}
+	v.plain = (*plain)(a)
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	elems, err := unmarshalElems(v.Elem)
+	a.Elem = elems
+	return err
+}
+
+// MarshalJSON marshals l with its URL as a string.
+func (l Link) MarshalJSON() ([]byte, error) {
+	type plain Link
+	u := ""
+	if l.URL != nil {
+		u = l.URL.String()
+	}
+	return json.Marshal(struct {
+		plain
+		URL string
+	}{plain(l), u})
+}
+
+// UnmarshalJSON unmarshals a Link marshalled by MarshalJSON.
+func (l *Link) UnmarshalJSON(b []byte) error {
+	type plain Link
+	var v struct {
+		*plain
+		URL string
+	}
+	v.plain = (*plain)(l)
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	l.URL = nil
+	if v.URL == "" {
+		return nil
+	}
+	u, err := url.Parse(v.URL)
+	l.URL = u
+	return err
+}
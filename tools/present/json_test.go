@@ -0,0 +1,89 @@
+package present
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJSON(t *testing.T) {
+	const src = `Title
+Subtitle
+15:04 2 Jan 2015
+Tags: go
+
+Author Name
+https://example.com/
+
+* Section
+
+Some *text*.
+
+- one
+  - two
+
+| a | b |
+
+.play hello.go
+
+.link https://go.dev Go
+
+.math x^2
+
+** Subsection
+
+.diagram
+	a -> b
+`
+	PlayEnabled = true
+	defer func() { PlayEnabled = false }()
+	doc, err := testContext().Parse(strings.NewReader(src), "doc.slide", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b, []byte(`{"Type":"link","Cmd":".link https://go.dev Go","Label":"Go","URL":"https://go.dev"}`)) {
+		t.Errorf("no typed link element in\n%s", b)
+	}
+
+	doc2 := new(Doc)
+	if err := json.Unmarshal(b, doc2); err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	for _, e := range doc2.Sections[0].Elem {
+		types = append(types, reflect.TypeOf(e).Name())
+	}
+	want := []string{"Text", "List", "Table", "Code", "Link", "Math", "Section"}
+	if !reflect.DeepEqual(types, want) {
+		t.Errorf("got elements %v, want %v", types, want)
+	}
+	if _, ok := doc2.Sections[0].Elem[6].(Section).Elem[0].(Diagram); !ok {
+		t.Errorf("subsection lost its diagram")
+	}
+	if l := doc2.Sections[0].Elem[4].(Link); l.URL == nil || l.URL.String() != "https://go.dev" {
+		t.Errorf("got link URL %v, want https://go.dev", l.URL)
+	}
+	if _, ok := doc2.Authors[0].Elem[0].(Text); !ok {
+		t.Errorf("author lost its text")
+	}
+	b2, err := json.Marshal(doc2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, b2) {
+		t.Errorf("marshal(unmarshal(b)) != b\n%s\n%s", b, b2)
+	}
+
+	if err := json.Unmarshal([]byte(`{"Sections":[{"Elem":[{"Type":"nope"}]}]}`), new(Doc)); err == nil {
+		t.Errorf("unknown element type: no error")
+	}
+	if err := json.Unmarshal([]byte(`{"Sections":[{"Elem":[{"Type":"link","URL":"%zz"}]}]}`), new(Doc)); err == nil {
+		t.Errorf("bad link URL: no error")
+	}
+}
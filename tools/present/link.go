@@ -1,10 +1,103 @@
 package present
 
-import "net/url"
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+)
+
+func init() {
+	RegisterCommand(&Command{
+		Name: "link",
+		Doc:  "Shows a link on a line of its own.",
+		Args: []Arg{
+			{Name: "url", Type: StringArg, Doc: "an http, https or mailto URL, or a relative path"},
+			{Name: "label", Type: TextArg, Optional: true, Doc: "the text of the link; the URL without its scheme if not given"},
+		},
+		Parse: parseLink,
+	})
+}
 
 type Link struct {
+	Cmd   string // original command from present source; for author links, the typed line such as "GitHub: gopher", if any
 	URL   *url.URL
 	Label string
 }
 
-func (l Link) TemplateName() string { return "link" }
\ No newline at end of file
+func (l Link) TemplateName() string { return "link" }
+
+func parseLink(ctx *Context, c *CommandLine) (Elem, error) {
+	raw := c.String("url")
+	url, err := url.Parse(raw)
+	if err != nil {
+		return nil, &ParseError{File: c.File, Line: c.Line, Column: c.column("url"), Code: CodeBadCommand, Msg: err.Error(), Err: err}
+	}
+	if !safeURL(raw) {
+		return nil, errorf(c.File, c.Line, c.column("url"), CodeBadURL, "bad link %q: must be http, https, mailto or a relative path", raw)
+	}
+	label := c.String("label")
+	if label == "" {
+		scheme := url.Scheme + "://"
+		if url.Scheme == "mailto" {
+			scheme = "mailto:"
+		}
+		label = strings.Replace(url.String(), scheme, "", 1)
+	}
+	return Link{Cmd: c.Cmd, URL: url, Label: label}, nil
+}
+
+func renderLink(href, text string) string {
+	text = font(text)
+	if text == "" {
+		text = href
+	}
+	// Open links in new window only when their url is absolute.
+	target := "_blank"
+	if u, err := url.Parse(href); err != nil {
+		log.Println("renderLink parsing url:", err)
+	} else if !u.IsAbs() || u.Scheme == "javascript" {
+		target = "_self"
+	}
+
+	return fmt.Sprintf(`<a href="%s" target="%s">%s</a>`, href, target, text)
+}
+
+// parseInlineLink parses an inline link at the start of s, and returns
+// a rendered HTML link and the total length of the raw inline link.
+// If no inline link is present, it returns all zeroes.
+func parseInlineLink(s string) (link string, length int) {
+	if !strings.HasPrefix(s, "[[") {
+		return
+	}
+	end := strings.Index(s, "]]")
+	if end == -1 {
+		return
+	}
+	urlEnd := strings.Index(s, "]")
+	rawURL := s[2:urlEnd]
+	const badURLChars = `<>"{}|\^[] ` + "`" // per RFC2396 section 2.4.3
+	if strings.ContainsAny(rawURL, badURLChars) {
+		return
+	}
+	if urlEnd == end {
+		simpleURL := ""
+		url, err := url.Parse(rawURL)
+		if err == nil {
+			// If the URL is http://foo.com, drop the http://
+			// In other words, render [[http://golang.org]] as:
+			//   <a href="http://golang.org">golang.org</a>
+			if strings.HasPrefix(rawURL, url.Scheme+"://") {
+				simpleURL = strings.TrimPrefix(rawURL, url.Scheme+"://")
+			} else if strings.HasPrefix(rawURL, url.Scheme+":") {
+				simpleURL = strings.TrimPrefix(rawURL, url.Scheme+":")
+			}
+		}
+		return renderLink(rawURL, simpleURL), end + 2
+	}
+	if s[urlEnd:urlEnd+2] != "][" {
+		return
+	}
+	text := s[urlEnd+2 : end]
+	return renderLink(rawURL, text), end + 2
+}
@@ -0,0 +1,204 @@
+package present
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Codes of the problems reported by Lint in addition to parse errors.
+const (
+	CodeMissingImage    = "missing-image"    // an .image refers to a local file that doesn't exist
+	CodeMissingVideo    = "missing-video"    // a .video refers to a local file that doesn't exist
+	CodeUnusedHighlight = "unused-highlight" // an HL marker highlights no line
+	CodeDuplicateTitle  = "duplicate-title"  // two sections have the same title
+	CodeEmptySection    = "empty-section"    // a section has no content
+	CodeBadDate         = "bad-date"         // a header line looks like a date parseTime rejects
+	CodeLongCode        = "long-code"        // a code block is longer than allowed
+	CodeQuizAnswer      = "quiz-answer"      // a quiz doesn't have exactly one answer naming a choice
+)
+
+// LintOptions configures Lint.
+type LintOptions struct {
+	// MaxCodeLines is the number of lines a code block may have.
+	// Zero means no limit.
+	MaxCodeLines int
+}
+
+// Lint parses the document read from r and returns the parse errors
+// together with problems that don't stop parsing but are likely mistakes,
+// sorted by position.
+func (ctx *Context) Lint(r io.Reader, name string, opt LintOptions) ErrorList {
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return ErrorList{{File: name, Code: CodeReadFile, Msg: err.Error(), Err: err}}
+	}
+
+	var errs ErrorList
+	type titled struct {
+		title string
+		pos   Pos
+	}
+	var titles []titled
+
+	c := *ctx
+	c.visit = func(e Elem, pos Pos, cmd string) {
+		switch e := e.(type) {
+		case Section:
+			titles = append(titles, titled{e.Title, pos})
+			if !hasContent(e) {
+				errs.Add(errorf(pos.File, pos.Line, 0, CodeEmptySection, "section %q is empty", e.Title))
+			}
+		case Code:
+			if e.Highlight != "" && !e.Highlighted {
+				hl := "HL" + e.Highlight
+				errs.Add(errorf(pos.File, pos.Line, strings.LastIndex(cmd, hl)+1, CodeUnusedHighlight, "%s marks no line of %s", hl, e.FileName))
+			}
+			if n := bytes.Count(e.Raw, []byte("\n")); opt.MaxCodeLines > 0 && n > opt.MaxCodeLines {
+				errs.Add(errorf(pos.File, pos.Line, 0, CodeLongCode, "code block has %d lines; more than %d", n, opt.MaxCodeLines))
+			}
+		case Image:
+			if !c.fileExists(pos.File, e.URL) {
+				errs.Add(errorf(pos.File, pos.Line, strings.Index(cmd, e.URL)+1, CodeMissingImage, "image %s not found", e.URL))
+			}
+		case Video:
+			if !c.fileExists(pos.File, e.URL) {
+				errs.Add(errorf(pos.File, pos.Line, strings.Index(cmd, e.URL)+1, CodeMissingVideo, "video %s not found", e.URL))
+			}
+		case Quiz:
+			lintQuiz(e, pos, &errs)
+		}
+	}
+	if _, err := c.Parse(bytes.NewReader(src), name, 0); err != nil {
+		addError(&errs, err, name, 0)
+	}
+
+	// Sections are visited once their subsections are done; put them
+	// back in source order, included files after the document.
+	sort.SliceStable(titles, func(i, j int) bool {
+		a, b := titles[i].pos, titles[j].pos
+		if a.File != b.File {
+			return a.File == name
+		}
+		return a.Line < b.Line
+	})
+	first := make(map[string]Pos)
+	for _, t := range titles {
+		if p, ok := first[t.title]; ok {
+			at := fmt.Sprintf("line %d", p.Line)
+			if p.File != t.pos.File {
+				at = p.String()
+			}
+			errs.Add(errorf(t.pos.File, t.pos.Line, 0, CodeDuplicateTitle, "section title %q already used at %s", t.title, at))
+			continue
+		}
+		first[t.title] = t.pos
+	}
+
+	lintHeaderDates(name, src, &errs)
+	errs.Sort()
+	return errs
+}
+
+// hasContent reports whether s has elements other than comments.
+func hasContent(s Section) bool {
+	for _, e := range s.Elem {
+		if _, ok := e.(Comment); !ok {
+			return true
+		}
+	}
+	return false
+}
+
+var headerKeyRE = regexp.MustCompile(`^[A-Za-z][A-Za-z-]*:`)
+
+var dateLikeRE = regexp.MustCompile(`(?i)\b(\d{1,4}[-/.]\d{1,2}[-/.]\d{1,4}|\d{1,2}:\d{2}|(jan|feb|mar|apr|may|jun|jul|aug|sep|oct|nov|dec)[a-z]*\.? \d{1,4})\b`)
+
+// lintQuiz reports a quiz at pos without exactly one answer, or whose
+// answer names no choice. Problems are reported on the Answer: line, or
+// on the .quiz line if there is none.
+func lintQuiz(q Quiz, pos Pos, errs *ErrorList) {
+	line := pos.Line
+	for i, l := range q.Body {
+		if strings.HasPrefix(strings.TrimSpace(l), "Answer:") {
+			line = pos.Line + 1 + i
+			break
+		}
+	}
+	switch len(q.Answers) {
+	case 0:
+		errs.Add(errorf(pos.File, line, 0, CodeQuizAnswer, "quiz has no answer; want an Answer: line with the key of the right choice"))
+	case 1:
+		if _, ok := q.Choice(q.Answers[0]); !ok {
+			errs.Add(errorf(pos.File, line, 0, CodeQuizAnswer, "quiz answer %s names no choice", q.Answers[0]))
+		}
+	default:
+		errs.Add(errorf(pos.File, line, 0, CodeQuizAnswer, "quiz has %d answers (%s); want exactly one", len(q.Answers), strings.Join(q.Answers, ", ")))
+	}
+}
+
+// lintHeaderDates reports header lines that look like dates but aren't in
+// one of the formats parseTime accepts, and so end up as the subtitle or
+// as an unexpected header line.
+func lintHeaderDates(name string, src []byte, errs *ErrorList) {
+	lines, err := readLines(bytes.NewReader(src))
+	if err != nil {
+		return
+	}
+	if _, ok := lines.nextNonEmpty(); !ok { // title
+		return
+	}
+	for {
+		text, ok := lines.next()
+		if !ok || text == "" {
+			return
+		}
+		if isSpeakerNote(text) || headerKeyRE.MatchString(text) {
+			continue
+		}
+		if _, ok := parseTime(text); !ok && dateLikeRE.MatchString(text) {
+			errs.Add(errorf(name, lines.line, dateLikeRE.FindStringIndex(text)[0]+1, CodeBadDate,
+				`%q looks like a date but isn't in the form "2 Jan 2006", "15:04 2 Jan 2006" or ISO 8601 "2006-01-02T15:04:05Z07:00"`, text))
+		}
+	}
+}
+
+// fileExists reports whether the image or video at u is present. Files on
+// other hosts are assumed to exist. Relative paths are looked up next to the
+// document; absolute paths are served from a root the document lives
+// under, so they are looked up in each of its parent directories, or in
+// the root directory of the Context's FS.
+func (ctx *Context) fileExists(doc, u string) bool {
+	p, err := url.Parse(u)
+	if err != nil || p.Scheme != "" || p.Host != "" {
+		return true
+	}
+	if ctx.FS != nil || !path.IsAbs(p.Path) {
+		name, err := ctx.resolve(doc, p.Path)
+		if err == nil {
+			_, err = ctx.readFile(name)
+		}
+		return err == nil
+	}
+	dir := filepath.Dir(doc)
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	for {
+		if _, err := ctx.readFile(filepath.Join(dir, filepath.FromSlash(p.Path))); err == nil {
+			return true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
+}
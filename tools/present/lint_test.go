@@ -0,0 +1,121 @@
+package present
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// lintContext returns a Context reading testFiles, a few media files
+// and an HTML file with bold text.
+func lintContext() *Context {
+	return &Context{ReadFile: func(name string) ([]byte, error) {
+		switch filepath.Base(name) {
+		case "gopher.png", "intro.mp4":
+			return []byte("media"), nil
+		case "bold.html":
+			return []byte("<p><b>bold</b></p> // HLy\n"), nil
+		}
+		if b, ok := testFiles[filepath.Base(name)]; ok {
+			return []byte(b), nil
+		}
+		return nil, os.ErrNotExist
+	}}
+}
+
+func TestLint(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		in   string
+		opt  LintOptions
+		want []string // "line:column code" of each problem
+	}{
+		{
+			"clean",
+			"Title\n2 Jan 2024\n\n* One\n\nText.\n\n.code hello.go HLx\n\n.image gopher.png\n\n.video intro.mp4 video/mp4\n",
+			LintOptions{MaxCodeLines: 10},
+			nil,
+		},
+		{
+			"empty section",
+			"Title\n\n* Empty\n\n* Full\n\nText.\n",
+			LintOptions{},
+			[]string{"3:0 empty-section"},
+		},
+		{
+			"section with only a subsection",
+			"Title\n\n* Outer\n\n** Inner\n\nText.\n",
+			LintOptions{},
+			nil,
+		},
+		{
+			"unused highlight",
+			"Title\n\n* S\n\n.code hello.go HLy\n.code hello.go HLx\n",
+			LintOptions{},
+			[]string{"5:16 unused-highlight"},
+		},
+		{
+			// Bold text in the file doesn't count as a highlight.
+			"unused highlight in HTML",
+			"Title\n\n* S\n\n.code bold.html HLz\n",
+			LintOptions{},
+			[]string{"5:17 unused-highlight"},
+		},
+		{
+			"long code",
+			"Title\n\n* S\n\n.code hello.go\n",
+			LintOptions{MaxCodeLines: 5},
+			[]string{"5:0 long-code"},
+		},
+		{
+			"long code without a limit",
+			"Title\n\n* S\n\n.code hello.go\n",
+			LintOptions{},
+			nil,
+		},
+		{
+			"missing image and video",
+			"Title\n\n* S\n\n.image missing.png\n.image https://go.dev/gopher.png\n.video  lost.mp4 video/mp4\n",
+			LintOptions{},
+			[]string{"5:8 missing-image", "7:9 missing-video"},
+		},
+		{
+			"bad header date",
+			"Title\n2024-13-45\n\n* S\n\nText.\n",
+			LintOptions{},
+			[]string{"2:1 bad-date"},
+		},
+		{
+			"bad header date in text",
+			"Title\nUpdated Jan 2024\n\n* S\n\nText.\n",
+			LintOptions{},
+			[]string{"2:9 bad-date"},
+		},
+		{
+			"duplicate titles",
+			"Title\n\n* Same\n\nText.\n\n* Same\n\nText.\n",
+			LintOptions{},
+			[]string{"7:0 duplicate-title"},
+		},
+		{
+			"parse errors first in line order",
+			"Title\n\n* S\n\n.nope\n.image missing.png\n",
+			LintOptions{},
+			[]string{"5:0 unknown-command", "6:8 missing-image"},
+		},
+	} {
+		errs := lintContext().Lint(strings.NewReader(tt.in), "doc.slide", tt.opt)
+		var got []string
+		for _, e := range errs {
+			if e.File != "doc.slide" {
+				t.Errorf("%s: error in file %q: %v", tt.name, e.File, e)
+			}
+			got = append(got, fmt.Sprintf("%d:%d %s", e.Line, e.Column, e.Code))
+		}
+		if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package present
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Lists are written one item per line, starting with "- " or, for
+// numbered lists, "1. ". An item indented further than the one above it
+// starts a list nested in that item; an indented line that doesn't start
+// an item continues the item above it.
+//
+//	- fruit
+//	  - apples,
+//	    pears
+//	1. first
+//	2. second
+
+// listItemRE matches a list item, with the number of a numbered item in
+// its first group and the text in the second.
+var listItemRE = regexp.MustCompile(`^(?:-|(\d{1,9})\.)\s+(.*)$`)
+
+// listLine is a line of a list that starts an item.
+type listLine struct {
+	indent int
+	number string // for numbered items
+	text   string
+}
+
+// parseList parses the list starting with the line text, whose items
+// match itemRE. The text of each item is passed through inline, if not
+// nil, to turn it into present markup.
+func parseList(text string, lines *Lines, itemRE *regexp.Regexp, inline func(string) string) Elem {
+	var items []listLine
+	ok := true
+	for ok {
+		trimmed := strings.TrimLeft(text, " \t")
+		indent := listIndent(text[:len(text)-len(trimmed)])
+		if m := itemRE.FindStringSubmatch(trimmed); m != nil {
+			items = append(items, listLine{indent, m[1], trimRight(m[2])})
+		} else if indent > 0 && len(items) > 0 && trimmed != "" {
+			items[len(items)-1].text += " " + trimRight(trimmed)
+		} else {
+			break
+		}
+		text, ok = lines.next()
+	}
+	lines.back()
+	if inline != nil {
+		for i := range items {
+			items[i].text = inline(items[i].text)
+		}
+	}
+	l, _ := buildList(items, 0)
+	return *l
+}
+
+// listIndent returns the width of the leading space ws, counting a tab as
+// four spaces.
+func listIndent(ws string) int {
+	return len(ws) + 3*strings.Count(ws, "\t")
+}
+
+// buildList returns the list starting at items[i] and the index of the
+// first item after it. The list ends at an item indented less than its
+// first one.
+func buildList(items []listLine, i int) (*List, int) {
+	l := &List{Ordered: items[i].number != ""}
+	if l.Ordered {
+		l.Start, _ = strconv.Atoi(items[i].number)
+	}
+	indent := items[i].indent
+	for i < len(items) && items[i].indent >= indent {
+		if items[i].indent == indent || len(l.Items) == 0 {
+			l.Items = append(l.Items, ListItem{Text: items[i].text})
+			i++
+			continue
+		}
+		var sub *List
+		sub, i = buildList(items, i)
+		last := &l.Items[len(l.Items)-1]
+		if last.List == nil {
+			last.List = sub
+		} else {
+			last.List.Items = append(last.List.Items, sub.Items...)
+		}
+	}
+	return l, i
+}
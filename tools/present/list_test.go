@@ -0,0 +1,57 @@
+package present
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestList(t *testing.T) {
+	const src = `Title
+
+* Lists
+
+- fruit
+  - apples,
+    pears
+  - plums
+- *vegetables*
+3. not a new list
+
+1. first
+	1. nested
+2. second
+`
+	doc, err := testContext().Parse(strings.NewReader(src), "doc.article", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Elem{
+		List{Items: []ListItem{
+			{Text: "fruit", List: &List{Items: []ListItem{{Text: "apples, pears"}, {Text: "plums"}}}},
+			{Text: "*vegetables*"},
+			{Text: "not a new list"},
+		}},
+		List{Ordered: true, Start: 1, Items: []ListItem{
+			{Text: "first", List: &List{Ordered: true, Start: 1, Items: []ListItem{{Text: "nested"}}}},
+			{Text: "second"},
+		}},
+	}
+	if got := doc.Sections[0].Elem; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	checkRoundTrip(t, testContext(), "doc.article", doc)
+
+	const md = "# Title\n\n## Lists\n\n3) **three**\n4) four\n   * sub\n"
+	doc, err = testContext().Parse(strings.NewReader(md), "doc.md", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantMD := List{Ordered: true, Start: 3, Items: []ListItem{
+		{Text: "*three*"},
+		{Text: "four", List: &List{Items: []ListItem{{Text: "sub"}}}},
+	}}
+	if got := doc.Sections[0].Elem[0]; !reflect.DeepEqual(got, wantMD) {
+		t.Errorf("Markdown: got %+v, want %+v", got, wantMD)
+	}
+}
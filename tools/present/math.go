@@ -0,0 +1,443 @@
+package present
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+func init() {
+	RegisterCommand(&Command{
+		Name:  "math",
+		Doc:   "Displays a TeX formula, given on the line or in the indented block below.",
+		Block: true,
+		Args:  []Arg{{Name: "formula", Type: TextArg, Optional: true, Doc: "the formula, if not in a block"}},
+		Parse: parseMath,
+	})
+}
+
+/*
+	Formulas are written in a subset of TeX, either after .math on the
+	same line or in the indented block below it,
+
+		.math
+			T(n) = \sum_{i=1}^{n} \frac{n}{2^i} = O(n \log n)
+
+	or between dollar signs in text: $\gcd(a, b) = \gcd(b, a \bmod b)$.
+	They are rendered to MathML, which browsers display natively. The
+	subset covers letters, numbers and operators, ^ and _ scripts,
+	groups in braces, \frac, \sqrt, \binom, \text, \left and \right, and
+	named symbols such as Greek letters, relations and functions like
+	\log.
+*/
+
+// Math is a formula displayed on its own.
+type Math struct {
+	Cmd    string   // original command from present source
+	Body   []string // indented lines following the command
+	TeX    string
+	MathML template.HTML
+}
+
+func (m Math) TemplateName() string { return "math" }
+
+func parseMath(ctx *Context, c *CommandLine) (Elem, error) {
+	tex, body := c.String("formula"), c.Body
+	if tex != "" && len(body) > 0 {
+		return nil, c.Errorf("formula", ".math has both a formula and a block")
+	}
+	if tex == "" {
+		tex = strings.TrimSpace(strings.Join(body, "\n"))
+	}
+	if tex == "" {
+		return nil, c.Errorf("", "usage: .math formula, or .math followed by an indented formula")
+	}
+	mathml, err := MathML(tex, true)
+	if err != nil {
+		return nil, c.Errorf("formula", "%v", err)
+	}
+	return Math{Cmd: c.Cmd, Body: body, TeX: tex, MathML: mathml}, nil
+}
+
+// MathML returns the MathML for the TeX formula tex, displayed as a block
+// if display is set and inline otherwise.
+func MathML(tex string, display bool) (template.HTML, error) {
+	p := &mathParser{src: tex}
+	row, err := p.row(0)
+	if err != nil {
+		return "", err
+	}
+	if p.pos < len(p.src) {
+		return "", fmt.Errorf("math: unexpected %q", p.src[p.pos])
+	}
+	mode := "inline"
+	if display {
+		mode = "block"
+	}
+	return template.HTML(`<math xmlns="http://www.w3.org/1998/Math/MathML" display="` + mode + `">` + row + `</math>`), nil
+}
+
+type mathParser struct {
+	src string
+	pos int
+}
+
+func (p *mathParser) skipSpace() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *mathParser) peek() byte {
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+// row parses atoms up to the end of the input or the byte stop.
+func (p *mathParser) row(stop byte) (string, error) {
+	var atoms []string
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.src) || p.peek() == stop && stop != 0 {
+			break
+		}
+		if c := p.peek(); c == '}' || c == ']' && stop == ']' {
+			break
+		}
+		a, err := p.scripted()
+		if err != nil {
+			return "", err
+		}
+		atoms = append(atoms, a)
+	}
+	return mrow(atoms), nil
+}
+
+func mrow(atoms []string) string {
+	if len(atoms) == 1 {
+		return atoms[0]
+	}
+	return "<mrow>" + strings.Join(atoms, "") + "</mrow>"
+}
+
+// scripted parses an atom with its subscript and superscript.
+func (p *mathParser) scripted() (string, error) {
+	base, err := p.atom()
+	if err != nil {
+		return "", err
+	}
+	var sub, sup string
+	for {
+		p.skipSpace()
+		c := p.peek()
+		if c != '_' && c != '^' && c != '\'' {
+			break
+		}
+		p.pos++
+		if c == '\'' {
+			sup += "<mo>′</mo>"
+			continue
+		}
+		arg, err := p.arg()
+		if err != nil {
+			return "", err
+		}
+		if c == '_' {
+			sub = arg
+		} else {
+			sup += arg
+		}
+	}
+	switch {
+	case sub != "" && sup != "":
+		return "<msubsup>" + base + sub + sup + "</msubsup>", nil
+	case sub != "":
+		return "<msub>" + base + sub + "</msub>", nil
+	case sup != "":
+		return "<msup>" + base + sup + "</msup>", nil
+	}
+	return base, nil
+}
+
+// arg parses the argument of a command or script: a group in braces or a
+// single symbol.
+func (p *mathParser) arg() (string, error) {
+	p.skipSpace()
+	switch c := p.peek(); {
+	case c == 0:
+		return "", fmt.Errorf("math: missing argument at end of %q", p.src)
+	case c == '{':
+		return p.atom()
+	case c >= '0' && c <= '9':
+		p.pos++
+		return "<mn>" + string(c) + "</mn>", nil
+	}
+	return p.atom()
+}
+
+// rawGroup parses a group in braces and returns its raw text.
+func (p *mathParser) rawGroup() (string, error) {
+	p.skipSpace()
+	if p.peek() != '{' {
+		return "", fmt.Errorf("math: expected { at %q", p.src[p.pos:])
+	}
+	depth := 0
+	for i := p.pos; i < len(p.src); i++ {
+		switch p.src[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				s := p.src[p.pos+1 : i]
+				p.pos = i + 1
+				return s, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("math: unbalanced { in %q", p.src)
+}
+
+// atom parses a symbol, a number, a group or a command.
+func (p *mathParser) atom() (string, error) {
+	p.skipSpace()
+	c := p.peek()
+	switch {
+	case c == '{':
+		p.pos++
+		row, err := p.row('}')
+		if err != nil {
+			return "", err
+		}
+		if p.peek() != '}' {
+			return "", fmt.Errorf("math: unbalanced { in %q", p.src)
+		}
+		p.pos++
+		if row == "" {
+			return "<mrow></mrow>", nil
+		}
+		return row, nil
+	case c == '}':
+		return "", fmt.Errorf("math: unbalanced } in %q", p.src)
+	case c == '\\':
+		return p.command()
+	case c >= '0' && c <= '9' || c == '.' && p.pos+1 < len(p.src) && isDigit(p.src[p.pos+1]):
+		start := p.pos
+		for p.pos < len(p.src) && (isDigit(p.src[p.pos]) || p.src[p.pos] == '.' && p.pos+1 < len(p.src) && isDigit(p.src[p.pos+1])) {
+			p.pos++
+		}
+		return "<mn>" + p.src[start:p.pos] + "</mn>", nil
+	}
+	r, n := utf8.DecodeRuneInString(p.src[p.pos:])
+	p.pos += n
+	switch {
+	case r == '-':
+		return "<mo>−</mo>", nil
+	case unicode.IsLetter(r):
+		return "<mi>" + html.EscapeString(string(r)) + "</mi>", nil
+	}
+	return mo(string(r)), nil
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func mo(s string) string {
+	return "<mo>" + html.EscapeString(s) + "</mo>"
+}
+
+// command parses a command starting with a backslash.
+func (p *mathParser) command() (string, error) {
+	p.pos++ // backslash
+	start := p.pos
+	for p.pos < len(p.src) && (p.src[p.pos] >= 'a' && p.src[p.pos] <= 'z' || p.src[p.pos] >= 'A' && p.src[p.pos] <= 'Z') {
+		p.pos++
+	}
+	name := p.src[start:p.pos]
+	if name == "" {
+		if p.pos >= len(p.src) {
+			return "", fmt.Errorf("math: stray \\ at end of %q", p.src)
+		}
+		// A single symbol: \{, \}, \|, \, and the like.
+		c := p.src[p.pos]
+		p.pos++
+		switch c {
+		case ',':
+			return `<mspace width="0.167em"/>`, nil
+		case ':', '>':
+			return `<mspace width="0.222em"/>`, nil
+		case ';':
+			return `<mspace width="0.278em"/>`, nil
+		case ' ':
+			return `<mspace width="0.333em"/>`, nil
+		case '!':
+			return "", nil
+		case '|':
+			return mo("‖"), nil
+		}
+		return mo(string(c)), nil
+	}
+
+	if s, ok := mathIdentifiers[name]; ok {
+		return "<mi>" + s + "</mi>", nil
+	}
+	if s, ok := mathOperators[name]; ok {
+		return mo(s), nil
+	}
+	if mathFunctions[name] {
+		return "<mi>" + name + "</mi>", nil
+	}
+	switch name {
+	case "frac", "dfrac", "tfrac", "binom":
+		num, err := p.arg()
+		if err != nil {
+			return "", err
+		}
+		den, err := p.arg()
+		if err != nil {
+			return "", err
+		}
+		if name == "binom" {
+			return `<mrow><mo>(</mo><mfrac linethickness="0">` + num + den + `</mfrac><mo>)</mo></mrow>`, nil
+		}
+		return "<mfrac>" + num + den + "</mfrac>", nil
+	case "sqrt":
+		p.skipSpace()
+		if p.peek() == '[' {
+			p.pos++
+			index, err := p.row(']')
+			if err != nil {
+				return "", err
+			}
+			if p.peek() != ']' {
+				return "", fmt.Errorf("math: unbalanced [ in %q", p.src)
+			}
+			p.pos++
+			radicand, err := p.arg()
+			if err != nil {
+				return "", err
+			}
+			return "<mroot>" + radicand + index + "</mroot>", nil
+		}
+		radicand, err := p.arg()
+		if err != nil {
+			return "", err
+		}
+		return "<msqrt>" + radicand + "</msqrt>", nil
+	case "text", "mathrm", "operatorname":
+		s, err := p.rawGroup()
+		if err != nil {
+			return "", err
+		}
+		if name == "text" {
+			return "<mtext>" + html.EscapeString(s) + "</mtext>", nil
+		}
+		return `<mi mathvariant="normal">` + html.EscapeString(s) + "</mi>", nil
+	case "left", "right", "big", "Big", "bigl", "bigr", "Bigl", "Bigr":
+		// Delimiters stretch by themselves in MathML.
+		p.skipSpace()
+		if p.peek() == '.' {
+			p.pos++
+			return "", nil
+		}
+		d, err := p.atom()
+		if err != nil {
+			return "", err
+		}
+		if !strings.HasPrefix(d, "<mo>") {
+			return "", fmt.Errorf(`math: \%s must be followed by a delimiter`, name)
+		}
+		return d, nil
+	case "bmod", "mod":
+		return "<mo>mod</mo>", nil
+	case "quad":
+		return `<mspace width="1em"/>`, nil
+	case "qquad":
+		return `<mspace width="2em"/>`, nil
+	}
+	return "", fmt.Errorf(`math: unknown command \%s`, name)
+}
+
+var mathIdentifiers = map[string]string{
+	"alpha": "α", "beta": "β", "gamma": "γ", "delta": "δ", "epsilon": "ϵ",
+	"varepsilon": "ε", "zeta": "ζ", "eta": "η", "theta": "θ", "vartheta": "ϑ",
+	"iota": "ι", "kappa": "κ", "lambda": "λ", "mu": "μ", "nu": "ν", "xi": "ξ",
+	"pi": "π", "rho": "ρ", "sigma": "σ", "tau": "τ", "upsilon": "υ",
+	"phi": "ϕ", "varphi": "φ", "chi": "χ", "psi": "ψ", "omega": "ω",
+	"Gamma": "Γ", "Delta": "Δ", "Theta": "Θ", "Lambda": "Λ", "Xi": "Ξ",
+	"Pi": "Π", "Sigma": "Σ", "Upsilon": "Υ", "Phi": "Φ", "Psi": "Ψ",
+	"Omega": "Ω", "infty": "∞", "emptyset": "∅", "partial": "∂", "nabla": "∇",
+	"ell": "ℓ",
+}
+
+var mathOperators = map[string]string{
+	"cdot": "·", "times": "×", "div": "÷", "pm": "±", "mp": "∓",
+	"le": "≤", "leq": "≤", "ge": "≥", "geq": "≥", "lt": "<", "gt": ">",
+	"ne": "≠", "neq": "≠", "approx": "≈", "equiv": "≡", "sim": "∼",
+	"ll": "≪", "gg": "≫", "to": "→", "rightarrow": "→", "leftarrow": "←",
+	"Rightarrow": "⇒", "Leftarrow": "⇐", "iff": "⟺", "implies": "⟹",
+	"in": "∈", "notin": "∉", "subset": "⊂", "subseteq": "⊆", "cup": "∪",
+	"cap": "∩", "forall": "∀", "exists": "∃", "neg": "¬", "land": "∧",
+	"lor": "∨", "oplus": "⊕", "circ": "∘", "bullet": "•", "star": "⋆",
+	"sum": "∑", "prod": "∏", "int": "∫", "ldots": "…", "cdots": "⋯",
+	"lfloor": "⌊", "rfloor": "⌋", "lceil": "⌈", "rceil": "⌉",
+	"langle": "⟨", "rangle": "⟩", "mid": "∣", "vert": "|", "Vert": "‖",
+}
+
+var mathFunctions = map[string]bool{
+	"log": true, "ln": true, "lg": true, "exp": true, "sin": true,
+	"cos": true, "tan": true, "max": true, "min": true, "gcd": true,
+	"lcm": true, "deg": true, "det": true, "lim": true, "sup": true,
+	"inf": true, "arg": true,
+}
+
+// styleMath returns s with the formulas between dollar signs rendered as
+// MathML and the text around them passed through text. A formula starts
+// at a dollar sign followed by a non-space and ends at the next dollar
+// sign, which must follow a non-space and not be followed by a digit, so
+// that prices such as $5 are left alone. Formulas that don't parse are
+// left as text.
+func styleMath(s string, text func(string) string) string {
+	var b strings.Builder
+	for {
+		start, end := findMath(s)
+		if start < 0 {
+			break
+		}
+		m, err := MathML(s[start+1:end], false)
+		if err != nil {
+			b.WriteString(text(s[:end+1]))
+			s = s[end+1:]
+			continue
+		}
+		b.WriteString(text(s[:start]))
+		b.WriteString(string(m))
+		s = s[end+1:]
+	}
+	b.WriteString(text(s))
+	return b.String()
+}
+
+// findMath returns the positions of the dollar signs around the first
+// formula in s, or -1, -1.
+func findMath(s string) (start, end int) {
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 >= len(s) || s[i+1] == ' ' || s[i+1] == '$' {
+			continue
+		}
+		j := strings.IndexByte(s[i+1:], '$')
+		if j < 0 {
+			break
+		}
+		j += i + 1
+		if s[j-1] != ' ' && (j+1 == len(s) || !isDigit(s[j+1])) {
+			return i, j
+		}
+		i = j - 1 // try again from the closing dollar sign
+	}
+	return -1, -1
+}
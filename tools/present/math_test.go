@@ -0,0 +1,87 @@
+package present
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMathML(t *testing.T) {
+	tests := []struct {
+		tex, want string
+	}{
+		{"x^2", "<msup><mi>x</mi><mn>2</mn></msup>"},
+		{"a_{i+1}", "<msub><mi>a</mi><mrow><mi>i</mi><mo>+</mo><mn>1</mn></mrow></msub>"},
+		{`\frac{n}{2}`, "<mfrac><mi>n</mi><mn>2</mn></mfrac>"},
+		{`\sqrt[3]{x}`, "<mroot><mi>x</mi><mn>3</mn></mroot>"},
+		{`O(n \log n)`, "<mrow><mi>O</mi><mo>(</mo><mi>n</mi><mi>log</mi><mi>n</mi><mo>)</mo></mrow>"},
+		{`\alpha \le 1.5`, "<mrow><mi>α</mi><mo>≤</mo><mn>1.5</mn></mrow>"},
+		{`\text{if } a<b`, "<mrow><mtext>if </mtext><mi>a</mi><mo>&lt;</mo><mi>b</mi></mrow>"},
+	}
+	for _, tt := range tests {
+		got, err := MathML(tt.tex, false)
+		if err != nil {
+			t.Errorf("%s: %v", tt.tex, err)
+			continue
+		}
+		want := `<math xmlns="http://www.w3.org/1998/Math/MathML" display="inline">` + tt.want + `</math>`
+		if string(got) != want {
+			t.Errorf("%s:\ngot  %s\nwant %s", tt.tex, got, want)
+		}
+	}
+	for _, tex := range []string{`\nope`, "{x", "x}", `\frac{1}`} {
+		if _, err := MathML(tex, false); err == nil {
+			t.Errorf("%s: no error", tex)
+		}
+	}
+}
+
+func TestStyleMath(t *testing.T) {
+	got := string(Style("costs $5 and $10, but $x_1$ is *fast*"))
+	if !strings.HasPrefix(got, "costs $5 and $10, but <math") || !strings.HasSuffix(got, "</math> is <b>fast</b>") {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestMathAndDiagram(t *testing.T) {
+	const src = `Title
+
+* Formulas
+
+.math a^2 + b^2 = c^2
+
+.math
+	\sum_{i=1}^{n} i =
+		\frac{n(n+1)}{2}
+
+.diagram LR
+	source -> lexer -> parser: tokens
+	parser -- 语法树
+
+text
+`
+	doc, err := testContext().Parse(strings.NewReader(src), "doc.article", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	elem := doc.Sections[0].Elem
+	if len(elem) != 4 {
+		t.Fatalf("got %d elements, want 4", len(elem))
+	}
+	if m := elem[1].(Math); m.TeX != "\\sum_{i=1}^{n} i =\n\t\\frac{n(n+1)}{2}" || !strings.Contains(string(m.MathML), `display="block"`) {
+		t.Errorf("got %+v", m)
+	}
+	svg := string(elem[2].(Diagram).SVG)
+	for _, s := range []string{">source</text>", ">语法树</text>", ">tokens</text>", "marker-end"} {
+		if !strings.Contains(svg, s) {
+			t.Errorf("diagram SVG does not contain %q:\n%s", s, svg)
+		}
+	}
+	checkRoundTrip(t, testContext(), "doc.article", doc)
+
+	for _, bad := range []string{".math \\nope", ".math", ".diagram\n\ta ->", ".diagram UP\n\ta"} {
+		_, err := testContext().Parse(strings.NewReader("Title\n\n* S\n\n"+bad+"\n"), "bad.article", 0)
+		if err == nil {
+			t.Errorf("%q: no error", bad)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package present
+
+import (
+	"bytes"
+	"html/template"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+/*
+	Markdown documents, the files whose names end in ".md", have the same
+	structure as the others, written the Markdown way: the title line
+	starts with "# ", sections start with "## ", subsections with "###"
+	and so on, and comments are HTML comments on a line of their own,
+	such as <!-- a comment -->.
+	Within a section,
+
+		```lang         fenced code becomes a Code element
+		![alt](url)     a line holding only an image becomes an Image
+		- item, 1. item list items become a List
+		| a | b |       table rows become a Table
+		> text          blockquote lines become speaker notes
+
+	and commands, indented preformatted text and paragraphs work as usual.
+	The inline markup of paragraphs and list items is turned into present
+	markup, so that the result renders with the same templates.
+*/
+
+// IsMarkdown reports whether the document with the given name is written
+// in Markdown rather than in present markup, which is the case when the
+// name ends in ".md". The content isn't looked at: a present document may
+// well start with a "# " comment.
+func IsMarkdown(name string) bool {
+	return filepath.Ext(name) == ".md"
+}
+
+var (
+	mdFenceRE = regexp.MustCompile("^(```+|~~~+)\\s*([^`\\s]*)")
+	mdListRE  = regexp.MustCompile(`^(?:[-*+]|(\d{1,9})[.)])\s+(.*)$`)
+	mdImageRE = regexp.MustCompile(`^!\[[^\]]*\]\(\s*(\S+?)(?:\s+"[^"]*")?\s*\)$`)
+)
+
+// mdHeading returns the level of a Markdown heading line, which is the
+// number of '#' it starts with, and its title. The level is 0 if text
+// isn't a heading.
+func mdHeading(text string) (level int, title string) {
+	for level < len(text) && text[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 || level < len(text) && text[level] != ' ' && text[level] != '\t' {
+		return 0, ""
+	}
+	title = strings.TrimSpace(text[level:])
+	// Drop an optional closing sequence of '#'s.
+	if t := strings.TrimRight(title, "#"); t == "" || strings.HasSuffix(t, " ") {
+		title = strings.TrimSpace(t)
+	}
+	return level, title
+}
+
+// parseMarkdownSections parses the Markdown sections at the level below
+// number. It is the Markdown counterpart of parseSections.
+func parseMarkdownSections(ctx *Context, name string, lines *Lines, number []int, errs *ErrorList) []Section {
+	var sections []Section
+	level := len(number) + 2 // the document title is level 1
+	for i := 1; ; i++ {
+		text, ok := lines.nextNonEmpty()
+		if !ok {
+			break
+		}
+		l, title := mdHeading(text)
+		if l != level {
+			lines.back()
+			break
+		}
+		section := Section{
+			Number: append(append([]int{}, number...), i),
+			Title:  title,
+			Pos:    Pos{name, lines.line},
+		}
+		text, ok = lines.nextNonEmpty()
+		for ok {
+			if l, _ := mdHeading(text); l > 0 && l <= level {
+				lines.back()
+				break
+			}
+			section.addComments(name, lines)
+			start := Pos{name, lines.line}
+			var e Elem
+			switch l, _ := mdHeading(text); {
+			case l == level+1:
+				lines.back()
+				for _, ss := range parseMarkdownSections(ctx, name, lines, section.Number, errs) {
+					section.add(ss, ss.Pos)
+				}
+			case l > level+1:
+				errs.Add(errorf(name, lines.line, 0, CodeBadHeader, "heading %q skips a level; expected %s", text, strings.Repeat("#", level+1)))
+			case mdFenceRE.MatchString(text):
+				e = parseFencedCode(ctx, name, lines, text, errs)
+			case unicode.IsSpace(firstRune(text)):
+				e = parsePre(text, lines)
+			case isTableRow(text):
+				e = parseTable(text, lines, mdInline)
+			case mdListRE.MatchString(text):
+				e = parseList(text, lines, mdListRE, mdInline)
+			case strings.HasPrefix(text, ">"):
+				for ok && strings.HasPrefix(text, ">") {
+					if note := strings.TrimSpace(text[1:]); note != "" {
+						section.Notes = append(section.Notes, note)
+					}
+					text, ok = lines.next()
+				}
+				lines.back()
+			case isSpeakerNote(text):
+				section.Notes = append(section.Notes, trimRight(text[2:]))
+			case strings.HasPrefix(text, "."):
+				e = parseCommand(ctx, name, lines, text, &section, errs)
+			case mdImageRE.MatchString(text):
+				url := mdImageRE.FindStringSubmatch(text)[1]
+				e = Image{Cmd: ".image " + url, URL: url}
+				ctx.visitElem(e, start, text)
+			default:
+				var p []string
+				for ok && strings.TrimSpace(text) != "" {
+					if l, _ := mdHeading(text); l > 0 || text[0] == '.' || text[0] == '>' || mdFenceRE.MatchString(text) {
+						// These start a new block.
+						lines.back()
+						break
+					}
+					p = append(p, mdInline(strings.TrimSpace(text)))
+					text, ok = lines.next()
+				}
+				if len(p) > 0 {
+					e = Text{Lines: p}
+				}
+			}
+			if e != nil {
+				section.add(e, start)
+			}
+			text, ok = lines.nextNonEmpty()
+		}
+		section.addComments(name, lines)
+		ctx.visitElem(section, section.Pos, "")
+		sections = append(sections, section)
+	}
+	return sections
+}
+
+func firstRune(s string) rune {
+	r, _ := utf8.DecodeRuneInString(s)
+	return r
+}
+
+// parseFencedCode parses the fenced code block starting with the line
+// text. The lines of the block are read as they are, so that comments
+// and headings inside it are kept.
+func parseFencedCode(ctx *Context, name string, lines *Lines, text string, errs *ErrorList) Elem {
+	m := mdFenceRE.FindStringSubmatch(text)
+	fence, lang := m[1], m[2]
+	start := lines.line
+	var src bytes.Buffer
+	for {
+		if lines.line >= len(lines.text) {
+			errs.Add(errorf(name, start, 0, CodeUnexpectedEOF, "unterminated code block"))
+			break
+		}
+		l := lines.text[lines.line]
+		lines.line++
+		if strings.HasPrefix(strings.TrimSpace(l), fence) && strings.Trim(strings.TrimSpace(l), fence[:1]) == "" {
+			break
+		}
+		src.WriteString(l)
+		src.WriteByte('\n')
+	}
+
+	cl := codeLines(src.Bytes(), 0, src.Len())
+	data := &codeTemplateData{Lines: formatLines(cl, "")}
+	highlightLines(data.Lines, lang)
+	var buf bytes.Buffer
+	if err := codeTemplate.Execute(&buf, data); err != nil {
+		addError(errs, err, name, start)
+		return nil
+	}
+	c := Code{Text: template.HTML(buf.String()), Raw: rawCode(cl)}
+	if lang != "" {
+		c.Ext = "." + lang
+	}
+	ctx.visitElem(c, Pos{name, start}, text)
+	return c
+}
+
+var (
+	mdLinkRE     = regexp.MustCompile(`^\[([^\]]+)\]\(\s*(\S+?)(?:\s+"[^"]*")?\s*\)`)
+	mdAutolinkRE = regexp.MustCompile(`^<([a-zA-Z][a-zA-Z0-9+.-]*:[^<>\s]+)>`)
+)
+
+// mdInline turns the Markdown inline markup in s into present markup:
+// emphasis into _italic_ and *bold* words, code spans into `code`
+// words, and links into [[url][label]].
+func mdInline(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch c {
+		case '\\':
+			if i+1 < len(s) && strings.IndexByte("\\`*_{}[]()#+-.!<>|~", s[i+1]) >= 0 {
+				b.WriteByte(s[i+1])
+				i += 2
+				continue
+			}
+		case '`':
+			n := len(s[i:]) - len(strings.TrimLeft(s[i:], "`"))
+			if end := strings.Index(s[i+n:], s[i:i+n]); end > 0 {
+				b.WriteString(presentFont('`', strings.TrimSpace(s[i+n:i+n+end])))
+				i += n + end + n
+				continue
+			}
+		case '[':
+			if m := mdLinkRE.FindStringSubmatch(s[i:]); m != nil {
+				b.WriteString("[[" + m[2] + "][" + mdInline(m[1]) + "]]")
+				i += len(m[0])
+				continue
+			}
+		case '<':
+			if m := mdAutolinkRE.FindStringSubmatch(s[i:]); m != nil {
+				b.WriteString("[[" + m[1] + "]]")
+				i += len(m[0])
+				continue
+			}
+		case '*', '_':
+			n := 1
+			if i+1 < len(s) && s[i+1] == c {
+				n = 2
+			}
+			if end := mdEmphasisEnd(s, i, n); end > 0 {
+				mark := byte('_')
+				if n == 2 {
+					mark = '*'
+				}
+				inner := strings.NewReplacer("**", "", "__", "", "`", "").Replace(s[i+n : end])
+				b.WriteString(presentFont(mark, inner))
+				i = end + n
+				continue
+			}
+		}
+		b.WriteByte(c)
+		i++
+	}
+	return b.String()
+}
+
+// mdEmphasisEnd returns the index of the delimiter closing the emphasis
+// that the n delimiters at s[i] open, or -1 if they don't open one.
+// Underscores only count at word boundaries, so snake_case is left alone.
+func mdEmphasisEnd(s string, i, n int) int {
+	delim := s[i : i+n]
+	if i+n >= len(s) || s[i+n] == ' ' || delim[0] == '_' && i > 0 && isWordByte(s[i-1]) {
+		return -1
+	}
+	for j := i + n + 1; j+n <= len(s); j++ {
+		if s[j:j+n] != delim || s[j-1] == ' ' {
+			continue
+		}
+		if delim[0] == '_' && j+n < len(s) && isWordByte(s[j+n]) {
+			continue
+		}
+		if n == 1 && j+1 < len(s) && s[j+1] == delim[0] {
+			// part of a longer run
+			j++
+			continue
+		}
+		return j
+	}
+	return -1
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= utf8.RuneSelf
+}
+
+// presentFont returns text marked up as a single present font word:
+// bracketed by mark, with mark doubled and spaces replaced by mark.
+func presentFont(mark byte, text string) string {
+	m := string(mark)
+	text = strings.Replace(text, m, m+m, -1)
+	text = strings.Replace(text, " ", m, -1)
+	return m + text + m
+}
@@ -0,0 +1,139 @@
+package present
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestMarkdown checks that a Markdown document parses to the same tree as
+// its present markup equivalent.
+func TestMarkdown(t *testing.T) {
+	const md = `# Title
+Subtitle
+Tags: go
+
+Author
+
+## Intro
+
+Some **bold words**, _italic_ and ` + "`code span`" + ` with a [link](https://go.dev).
+Keep snake_case and <https://golang.org>.
+
+![gopher](gopher.png)
+
+- one
+- two
+  continued
+1. three
+
+> a note
+
+<!-- a comment -->
+
+	pre
+
+.code hello.go
+
+### Sub
+
+Text.
+
+## Next
+
+Text.
+`
+	const legacy = `Title
+Subtitle
+Tags: go
+
+Author
+
+* Intro
+
+Some *bold*words*, _italic_ and ` + "`code`span`" + ` with a [[https://go.dev][link]].
+Keep snake_case and [[https://golang.org]].
+
+.image gopher.png
+
+- one
+- two continued
+- three
+
+: a note
+
+# a comment
+
+	pre
+
+.code hello.go
+
+** Sub
+
+Text.
+
+* Next
+
+Text.
+`
+	got, err := testContext().Parse(strings.NewReader(md), "doc.md", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := testContext().Parse(strings.NewReader(legacy), "doc.article", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(clearPos(got), clearPos(want)) {
+		t.Errorf("Markdown document differs:\ngot  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestMarkdownFencedCode(t *testing.T) {
+	const md = "# Title\n\n## Code\n\n```go\n// not a comment\n# not a heading\n```\n"
+	doc, err := testContext().Parse(strings.NewReader(md), "doc.md", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Sections) != 1 || len(doc.Sections[0].Elem) != 1 {
+		t.Fatalf("got sections %+v", doc.Sections)
+	}
+	code, ok := doc.Sections[0].Elem[0].(Code)
+	if !ok {
+		t.Fatalf("got %T, want Code", doc.Sections[0].Elem[0])
+	}
+	if want := "// not a comment\n# not a heading\n"; string(code.Raw) != want || code.Ext != ".go" {
+		t.Errorf("got %q (%s), want %q (.go)", code.Raw, code.Ext, want)
+	}
+}
+
+func TestMarkdownComments(t *testing.T) {
+	const md = "# Title\n\n## S\n\n<!-- a comment -->\n// not a comment\n<!-- nor this\n"
+	doc, err := testContext().Parse(strings.NewReader(md), "doc.md", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Elem{
+		Comment{Lines: []string{" a comment"}},
+		Text{Lines: []string{"// not a comment", "<!-- nor this"}},
+	}
+	if got := doc.Sections[0].Elem; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestMarkdownByName(t *testing.T) {
+	// A present document may start with a comment that looks like a
+	// Markdown title.
+	const src = "# Copyright notice.\nTitle\n\n* Section\n\nText.\n"
+	doc, err := testContext().Parse(strings.NewReader(src), "doc.article", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Title != "Title" || len(doc.Sections) != 1 || doc.Sections[0].Title != "Section" {
+		t.Errorf("got title %q and sections %+v", doc.Title, doc.Sections)
+	}
+	if IsMarkdown("doc.article") || IsMarkdown("doc.slide") || !IsMarkdown("doc.md") {
+		t.Error("IsMarkdown doesn't go by the extension")
+	}
+}
@@ -6,18 +6,21 @@ package present
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
 	"html/template"
 	"io"
+	"io/fs"
 	"io/ioutil"
+	"path"
+	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 	"unicode"
 	"unicode/utf8"
-	"net/url"
-	"log"
 )
 
 var (
@@ -39,6 +42,26 @@ func Template() *template.Template {
 	return template.New("").Funcs(funcs)
 }
 
+// Render renders the doc to the given writer using the provided template.
+func (d *Doc) Render(w io.Writer, t *template.Template) error {
+	data := struct {
+		*Doc
+		Template    *template.Template
+		PlayEnabled bool
+	}{d, t, PlayEnabled}
+	return t.ExecuteTemplate(w, "root", data)
+}
+
+// Render renders the section to the given writer using the provided template.
+func (s *Section) Render(w io.Writer, t *template.Template) error {
+	data := struct {
+		*Section
+		Template    *template.Template
+		PlayEnabled bool
+	}{s, t, PlayEnabled}
+	return t.ExecuteTemplate(w, "section", data)
+}
+
 func Register(name string, parser ParseFunc) {
 	if len(name) == 0 || name[0] == ';' {
 		panic("bad name in Register: " + name)
@@ -46,6 +69,23 @@ func Register(name string, parser ParseFunc) {
 	parsers["."+name] = parser
 }
 
+// BlockParseFunc parses a command followed by a block of indented lines,
+// its body, which is given without the indentation. The columns of
+// errors on the body lines count from the start of the body; the parser
+// adds the indentation back.
+type BlockParseFunc func(ctx *Context, fileName string, lineNumber int, cmd string, body []string) (Elem, error)
+
+var blockParsers = make(map[string]BlockParseFunc)
+
+// RegisterBlock registers a parser for the command name, which takes the
+// block of indented lines following it as its body.
+func RegisterBlock(name string, parser BlockParseFunc) {
+	if len(name) == 0 || name[0] == ';' {
+		panic("bad name in RegisterBlock: " + name)
+	}
+	blockParsers["."+name] = parser
+}
+
 type Doc struct {
 	Title      string
 	Subtitle   string
@@ -53,12 +93,51 @@ type Doc struct {
 	Authors    []Author
 	Tags       []string
 	TitleNotes []string
+	Comments   []string // text of the comment lines before the first section
 	Sections   []Section
+
+	Summary string            // one-line summary, for listings and search
+	OldURL  []string          // URLs the document used to live at
+	Lang    string            // language of the document, such as "zh" or "en"
+	Draft   bool              // not ready to be published
+	Meta    map[string]string // "Meta-Key: value" header lines, by Key
+
+	// Lesson ordering, used by the tour.
+	Order    int      // position among the lessons; 0 if unspecified
+	Requires []string // names of the lessons that must be read first
+	Level    string   // difficulty, such as "beginner"
 }
 
 // Author represents the person who wrote and/or is presenting the document.
 type Author struct {
-	Elem []Elem
+	Name        string // first line of text
+	Affiliation string
+	Email       string
+	Homepage    string // URL of the home page
+	GitHub      string // user name
+	Mastodon    string // handle, as @user@host
+	Twitter     string // user name
+
+	Elem []Elem // the lines showing the details, except the affiliation
+}
+
+// TextElem returns the first text elements of the author details.
+// This is used to display the author' name, job title, and company
+// without the contact details.
+func (p *Author) TextElem() (elems []Elem) {
+	for _, el := range p.Elem {
+		if _, ok := el.(Text); !ok {
+			break
+		}
+		elems = append(elems, el)
+	}
+	return
+}
+
+// Links returns the author details after those returned by TextElem:
+// the contact details, and any text that follows them.
+func (p *Author) Links() []Elem {
+	return p.Elem[len(p.TextElem()):]
 }
 
 // Section represents a section of a document (such as a presentation slide)
@@ -70,6 +149,67 @@ type Section struct {
 	Notes   []string //区块备注
 	Classes []string
 	Styles  []string
+
+	Pos     Pos   // position of the heading
+	ElemPos []Pos // position of each element of Elem
+}
+
+// Pos is a position in present source. Sections and elements spliced in
+// by .include keep the position they have in the included file.
+type Pos struct {
+	File string
+	Line int
+}
+
+func (p Pos) String() string { return fmt.Sprintf("%s:%d", p.File, p.Line) }
+
+// add appends e, which starts at pos, to the elements of s.
+func (s *Section) add(e Elem, pos Pos) {
+	s.Elem = append(s.Elem, e)
+	s.ElemPos = append(s.ElemPos, pos)
+}
+
+// HTMLAttributes for the section
+func (s Section) HTMLAttributes() template.HTMLAttr {
+	if len(s.Classes) == 0 && len(s.Styles) == 0 {
+		return ""
+	}
+
+	var class string
+	if len(s.Classes) > 0 {
+		class = fmt.Sprintf(`class=%q`, strings.Join(s.Classes, " "))
+	}
+	var style string
+	if len(s.Styles) > 0 {
+		style = fmt.Sprintf(`style=%q`, strings.Join(s.Styles, " "))
+	}
+	return template.HTMLAttr(strings.Join([]string{class, style}, " "))
+}
+
+// Sections contained within the section.
+func (s Section) Sections() (sections []Section) {
+	for _, e := range s.Elem {
+		if section, ok := e.(Section); ok {
+			sections = append(sections, section)
+		}
+	}
+	return
+}
+
+// Level returns the level of the given section.
+// The document title is level 1, main section 2, etc.
+func (s Section) Level() int {
+	return len(s.Number) + 1
+}
+
+// FormattedNumber returns a string containing the concatenation of the
+// numbers identifying a Section.
+func (s Section) FormattedNumber() string {
+	b := &bytes.Buffer{}
+	for _, n := range s.Number {
+		fmt.Fprintf(b, "%v.", n)
+	}
+	return b.String()
 }
 
 func (s Section) TemplateName() string { return "section" }
@@ -79,9 +219,63 @@ type Elem interface {
 }
 
 type Context struct {
+	// ReadFile reads the files that documents refer to, relative to the
+	// current directory. It is used when FS is nil; if it is nil too,
+	// ioutil.ReadFile is.
 	ReadFile func(filename string) ([]byte, error)
+
+	// FS, if set, is the file system files are read from instead, such
+	// as an archive or an embedded tree. Document names are then
+	// slash-separated paths relative to the directory Dir of FS, and
+	// the files a document refers to resolve relative to the document.
+	// References that would leave Dir are refused with ErrOutsideRoot.
+	FS  fs.FS
+	Dir string
+
+	// visit, if set, is called with each section and each element
+	// produced by a command, along with its position and command text.
+	visit func(e Elem, pos Pos, cmd string)
+
+	// including holds the names of the documents being parsed, the
+	// outermost first, to detect .include cycles.
+	including []string
 }
 
+// ErrOutsideRoot is returned for file references that would resolve to
+// a file outside the root directory of a Context's FS.
+var ErrOutsideRoot = errors.New("file is outside the root directory")
+
+// resolve returns the name of the file that ref, found in the document
+// named doc, refers to. With an FS, references starting with a slash are
+// relative to Dir.
+func (ctx *Context) resolve(doc, ref string) (string, error) {
+	if ctx.FS == nil {
+		return filepath.Join(filepath.Dir(doc), ref), nil
+	}
+	name := path.Join(path.Dir(doc), ref)
+	if path.IsAbs(ref) {
+		name = path.Clean(ref[1:])
+	}
+	if name == ".." || strings.HasPrefix(name, "../") || path.IsAbs(name) {
+		return "", &fs.PathError{Op: "open", Path: ref, Err: ErrOutsideRoot}
+	}
+	return name, nil
+}
+
+// readFile reads the file name, as returned by resolve.
+func (ctx *Context) readFile(name string) ([]byte, error) {
+	switch {
+	case ctx.FS != nil:
+		return fs.ReadFile(ctx.FS, path.Join(ctx.Dir, name))
+	case ctx.ReadFile != nil:
+		return ctx.ReadFile(name)
+	}
+	return ioutil.ReadFile(name)
+}
+
+// Parse parses a document from r. Problems in the input are reported as
+// an ErrorList; when they don't prevent it, the partially parsed document
+// is returned along with the list.
 func Parse(r io.Reader, name string, mode ParseMode) (*Doc, error) {
 	ctx := Context{ReadFile: ioutil.ReadFile}
 	return ctx.Parse(r, name, mode)
@@ -93,39 +287,95 @@ func (ctx *Context) Parse(r io.Reader, name string, mode ParseMode) (*Doc, error
 	if err != nil {
 		return doc, err
 	}
+	ctx.including = append(ctx.including, name)
+	defer func() { ctx.including = ctx.including[:len(ctx.including)-1] }()
+
+	md := IsMarkdown(name)
+	heading := "* "
+	if md {
+		heading = "## "
+		lines.comment, lines.commentEnd = "<!--", "-->"
+	}
 	for i := lines.line; i < len(lines.text); i++ {
-		if strings.HasPrefix(lines.text[i], "*") {
+		if strings.HasPrefix(lines.text[i], heading[:len(heading)-1]) {
 			break
 		}
 		if isSpeakerNote(lines.text[i]) {
-			doc.TitleNotes = append(doc.TitleNotes, lines.text[i][2:])
+			doc.TitleNotes = append(doc.TitleNotes, trimRight(lines.text[i][2:]))
 		}
 	}
-	err = parseHeader(doc, lines)
-	if err != nil {
-		return nil, err
+	var errs ErrorList
+	if err := parseHeader(doc, name, lines, &errs); err != nil {
+		errs.Add(err)
+		return nil, errs
+	}
+	if md {
+		doc.Title = strings.TrimSpace(strings.TrimPrefix(doc.Title, "#"))
 	}
 
 	if mode&TitlesOnly != 0 {
-		return doc, nil
+		return doc, errs.Err()
 	}
 
 	// Authors
-	if doc.Authors, err = parseAuthors(lines); err != nil {
-		return nil, err
+	var perr *ParseError
+	if doc.Authors, perr = parseAuthors(name, heading, lines, &errs); perr != nil {
+		errs.Add(perr)
+		return nil, errs
 	}
+	doc.Comments = lines.takeComments()
 
 	// Sections
-	if doc.Sections, err = parseSections(ctx, name, lines, []int{}); err != nil {
-		return nil ,err
+	if md {
+		doc.Sections = parseMarkdownSections(ctx, name, lines, []int{}, &errs)
+	} else {
+		doc.Sections = parseSections(ctx, name, lines, []int{}, &errs)
+	}
+	for i := range doc.Sections {
+		doc.Sections[i].renumber([]int{i + 1})
+	}
+
+	return doc, errs.Err()
+}
+
+// addError adds err, returned by the parser of a command at the given
+// line, to errs. Errors that aren't ParseErrors are given the position
+// of the command.
+func addError(errs *ErrorList, err error, file string, line int) {
+	var list ErrorList
+	var pe *ParseError
+	switch {
+	case errors.As(err, &list):
+		*errs = append(*errs, list...)
+	case errors.As(err, &pe):
+		errs.Add(pe)
+	default:
+		errs.Add(&ParseError{File: file, Line: line, Code: CodeBadCommand, Msg: err.Error(), Err: err})
 	}
+}
 
-	return doc, nil
+// indentColumns adds indent to the columns of the errors in err that are
+// on the body lines of the block command at the given line of file.
+func indentColumns(err error, file string, line, indent int) {
+	var list ErrorList
+	var pe *ParseError
+	switch {
+	case errors.As(err, &list):
+	case errors.As(err, &pe):
+		list = ErrorList{pe}
+	}
+	for _, e := range list {
+		if e.File == file && e.Line > line && e.Column > 0 {
+			e.Column += indent
+		}
+	}
 }
 
-func parseAuthors(lines *Lines) (authors []Author, err error) {
+// parseAuthors parses the author blocks, which end at the first line
+// starting with heading.
+func parseAuthors(name, heading string, lines *Lines, errs *ErrorList) (authors []Author, err *ParseError) {
 	if _, ok := lines.nextNonEmpty(); !ok {
-		return nil, errors.New("unexpected EOF")
+		return nil, errorf(name, len(lines.text), 0, CodeUnexpectedEOF, "unexpected EOF; expected authors")
 	}
 	lines.back()
 
@@ -133,11 +383,11 @@ func parseAuthors(lines *Lines) (authors []Author, err error) {
 	for {
 		text, ok := lines.next()
 		if !ok {
-			return nil, errors.New("unexpected EOF")
+			return nil, errorf(name, len(lines.text), 0, CodeUnexpectedEOF, "unexpected EOF; expected a section")
 		}
 
 		// If we find a section heading, we're done.
-		if strings.HasPrefix(text, "* ") {
+		if strings.HasPrefix(text, heading) {
 			lines.back()
 			break
 		}
@@ -147,37 +397,25 @@ func parseAuthors(lines *Lines) (authors []Author, err error) {
 		}
 
 		// If we encounter a blank we're done with this author.
-		if a != nil && len(text) == 0 {
-			authors = append(authors, *a)
-			a = nil
+		if len(text) == 0 {
+			if a != nil {
+				authors = append(authors, *a)
+				a = nil
+			}
 			continue
 		}
 		if a == nil {
 			a = new(Author)
 		}
 
-		// Parse the line. Those that
-		// - begin with @ are twitter names,
-		// - contain slashes are links, or
-		// - contain an @ symbol are an email address.
-		// The rest is just text.
-		var el Elem
-		switch {
-		case strings.HasPrefix(text, "@"):
-			el = parseURL("http://twitter.com/" + text[1:])
-		case strings.Contains(text, ":"):
-			el = parseURL(text)
-		case strings.Contains(text, "@"):
-			el = parseURL("mailto:" + text)
-		}
-		if l, ok := el.(Link); ok {
-			l.Label = text
-			el = l
+		el, err := parseAuthorLine(a, text)
+		if err != nil {
+			errs.Add(&ParseError{File: name, Line: lines.line, Column: authorValueColumn(text), Code: CodeBadURL, Msg: err.Error(), Err: err})
+			el = Text{Lines: []string{trimRight(text)}}
 		}
-		if el == nil {
-			el = Text{Lines: []string{text}}
+		if el != nil {
+			a.Elem = append(a.Elem, el)
 		}
-		a.Elem = append(a.Elem, el)
 	}
 	if a != nil {
 		authors = append(authors, *a)
@@ -185,16 +423,9 @@ func parseAuthors(lines *Lines) (authors []Author, err error) {
 	return authors, nil
 }
 
-func parseURL(text string) Elem {
-	u, err := url.Parse(text)
-	if err != nil {
-		log.Printf("Parse(%q): %v", text, err)
-		return nil
-	}
-	return Link{URL: u}
-}
-
-func parseSections(ctx *Context, name string, lines *Lines, number []int) ([]Section, error) {
+// parseSections parses the sections at the level below number. Problems
+// are added to errs and the offending lines skipped.
+func parseSections(ctx *Context, name string, lines *Lines, number []int, errs *ErrorList) []Section {
 	var sections []Section
 	for i := 1; ; i++ {
 		// Next non-empty line is title.
@@ -212,71 +443,34 @@ func parseSections(ctx *Context, name string, lines *Lines, number []int) ([]Sec
 		}
 		section := Section{
 			Number: append(append([]int{}, number...), i),
-			Title:  text[len(prefix)+1:],
+			Title:  strings.TrimSpace(text[len(prefix)+1:]),
+			Pos:    Pos{name, lines.line},
 		}
 		//取出下一非空行
 		text, ok = lines.nextNonEmpty()
 		//只要行不是"*"开头的
 		for ok && !lesserHeading(text, prefix) {
+			section.addComments(name, lines)
+			start := Pos{name, lines.line}
 			var e Elem
 			r, _ := utf8.DecodeRuneInString(text)
 			switch {
 			case unicode.IsSpace(r):
-				first := strings.IndexFunc(text, func(r rune) bool {
-					return !unicode.IsSpace(r)
-				})
-				if first < 0 {
-					break
-				}
-				indent := text[:first]
-				var s []string
-				for ok && (strings.HasPrefix(text, indent) || text == "") {
-					if text != "" {
-						text = text[first:]
-					}
-					s = append(s, text)
-					text, ok = lines.next()
-				}
-				lines.back()
-				pre := strings.Join(s, "\n")
-				pre = strings.Replace(pre, "\t", "    ", -1) // browsers treat tabs badly
-				pre = strings.TrimRightFunc(pre, unicode.IsSpace)
-				e = Text{Lines: []string{pre}, Pre: true}
-			case strings.HasPrefix(text, "- "):
-				var b []string
-				for ok && strings.HasPrefix(text, "- ") {
-					b = append(b, text[2:])
-					text, ok = lines.next()
-				}
-				lines.back()
-				e = List{Bullet: b}
+				e = parsePre(text, lines)
+			case isTableRow(text):
+				e = parseTable(text, lines, nil)
+			case listItemRE.MatchString(text):
+				e = parseList(text, lines, listItemRE, nil)
 			case isSpeakerNote(text):
-				section.Notes = append(section.Notes, text[2:])
+				section.Notes = append(section.Notes, trimRight(text[2:]))
 			case strings.HasPrefix(text, prefix+"* "):
 				lines.back()
-				subsecs, err := parseSections(ctx, name, lines, section.Number)
-				if err != nil {
-					return nil, err
-				}
+				subsecs := parseSections(ctx, name, lines, section.Number, errs)
 				for _, ss := range subsecs {
-					section.Elem = append(section.Elem, ss)
+					section.add(ss, ss.Pos)
 				}
 			case strings.HasPrefix(text, "."):
-				args := strings.Fields(text)
-				if args[0] == ".background" {
-					section.Classes = append(section.Classes, "background")
-					section.Styles = append(section.Styles, "background-image: url('"+args[1]+"')")
-					break
-				}
-				parser := parsers[args[0]]
-				if parser == nil {
-					return nil, fmt.Errorf("%s:%d: unknown command %q\n", name, lines.line, text)
-				}
-				t, err := parser(ctx, name, lines.line, text)
-				if err != nil {
-					return nil, err
-				}
-				e = t
+				e = parseCommand(ctx, name, lines, text, &section, errs)
 			default:
 				var l []string
 				for ok && strings.TrimSpace(text) != "" {
@@ -287,7 +481,7 @@ func parseSections(ctx *Context, name string, lines *Lines, number []int) ([]Sec
 					if strings.HasPrefix(text, `\.`) { // Backslash escapes initial period.
 						text = text[1:]
 					}
-					l = append(l, text)
+					l = append(l, trimRight(text))
 					text, ok = lines.next()
 				}
 				if len(l) > 0 {
@@ -295,24 +489,168 @@ func parseSections(ctx *Context, name string, lines *Lines, number []int) ([]Sec
 				}
 			}
 			if e != nil {
-				section.Elem = append(section.Elem, e)
+				section.add(e, start)
 			}
 			text, ok = lines.nextNonEmpty()
 		}
 		if isHeading.MatchString(text) {
 			lines.back()
 		}
+		section.addComments(name, lines)
+		ctx.visitElem(section, section.Pos, "")
 		sections = append(sections, section)
 	}
-	return sections, nil
+	return sections
+}
+
+// readBlock reads the block of indented lines that follows a command,
+// if there is one, and returns them without their common indentation,
+// along with its width. Blank lines within the block are kept.
+func readBlock(lines *Lines) ([]string, int) {
+	var body []string
+	indent := ""
+	for {
+		text, ok := lines.next()
+		if !ok {
+			break
+		}
+		if strings.TrimSpace(text) == "" {
+			if indent != "" {
+				body = append(body, "")
+				continue
+			}
+			break
+		}
+		if indent == "" {
+			indent = text[:len(text)-len(strings.TrimLeft(text, " \t"))]
+			if indent == "" {
+				break
+			}
+		}
+		if !strings.HasPrefix(text, indent) {
+			break
+		}
+		body = append(body, trimRight(text[len(indent):]))
+	}
+	lines.back()
+	for len(body) > 0 && body[len(body)-1] == "" {
+		body = body[:len(body)-1]
+	}
+	return body, len(indent)
+}
+
+// parsePre parses the block of preformatted text starting with the
+// indented line text. It returns nil if text is blank.
+func parsePre(text string, lines *Lines) Elem {
+	first := strings.IndexFunc(text, func(r rune) bool {
+		return !unicode.IsSpace(r)
+	})
+	if first < 0 {
+		return nil
+	}
+	indent := text[:first]
+	var s []string
+	ok := true
+	for ok && (strings.HasPrefix(text, indent) || text == "") {
+		if text != "" {
+			text = text[first:]
+		}
+		s = append(s, trimRight(text))
+		text, ok = lines.next()
+	}
+	lines.back()
+	pre := strings.Join(s, "\n")
+	pre = strings.Replace(pre, "\t", "    ", -1) // browsers treat tabs badly
+	pre = strings.TrimRightFunc(pre, unicode.IsSpace)
+	return Text{Lines: []string{pre}, Pre: true}
+}
+
+// parseCommand parses the command text, the last line read from lines,
+// in a section. It returns nil if the command is invalid or only changes
+// the section.
+func parseCommand(ctx *Context, name string, lines *Lines, text string, section *Section, errs *ErrorList) Elem {
+	line := lines.line
+	text = trimRight(text)
+	args := strings.Fields(text)
+	// usageCol is the column of the first word too many, or of the end
+	// of the line if a word is missing, for commands taking one argument.
+	usageCol := func() int {
+		if ws := words(text); len(ws) > 2 {
+			return ws[2].col
+		}
+		return len(text) + 1
+	}
+	if args[0] == ".include" {
+		if len(args) != 2 {
+			errs.Add(errorf(name, line, usageCol(), CodeBadCommand, "usage: .include file"))
+			return nil
+		}
+		ctx.include(name, line, text, words(text)[1], section, errs)
+		return nil
+	}
+	if args[0] == ".background" {
+		if len(args) != 2 {
+			errs.Add(errorf(name, line, usageCol(), CodeBadCommand, "usage: .background image"))
+			return nil
+		}
+		section.Classes = append(section.Classes, "background")
+		section.Styles = append(section.Styles, "background-image: url('"+args[1]+"')")
+		return nil
+	}
+	var (
+		e   Elem
+		err error
+	)
+	if parser := blockParsers[args[0]]; parser != nil {
+		body, indent := readBlock(lines)
+		e, err = parser(ctx, name, line, text, body)
+		indentColumns(err, name, line, indent)
+	} else if parser := parsers[args[0]]; parser != nil {
+		e, err = parser(ctx, name, line, text)
+	} else {
+		errs.Add(errorf(name, line, 0, CodeUnknownCommand, "unknown command %q", text))
+		return nil
+	}
+	if err != nil {
+		addError(errs, err, name, line)
+		return nil
+	}
+	ctx.visitElem(e, Pos{name, line}, text)
+	return e
+}
+
+// visitElem calls ctx.visit, if set.
+func (ctx *Context) visitElem(e Elem, pos Pos, cmd string) {
+	if ctx.visit != nil {
+		ctx.visit(e, pos, cmd)
+	}
 }
 
+// List is a list of items, numbered from Start if Ordered. Items may
+// hold lists of their own.
 type List struct {
-	Bullet []string
+	Ordered bool
+	Start   int
+	Items   []ListItem
+}
+
+// ListItem is an item of a List.
+type ListItem struct {
+	Text string // inline markup
+	List *List  // nested list, or nil
 }
 
 func (l List) TemplateName() string { return "list" }
 
+// Bullet returns the text of each item, leaving out nested lists.
+func (l List) Bullet() []string {
+	b := make([]string, len(l.Items))
+	for i, it := range l.Items {
+		b[i] = it.Text
+	}
+	return b
+}
+
 type Text struct {
 	Lines []string
 	Pre   bool
@@ -320,23 +658,49 @@ type Text struct {
 
 func (t Text) TemplateName() string { return "text" }
 
+// Comment holds the text of comment lines, which start with '#', or in
+// Markdown are HTML comments on a line of their own. They are kept so the document can be printed back, but
+// render as nothing.
+type Comment struct {
+	Lines []string
+}
+
+func (c Comment) TemplateName() string { return "comment" }
+
+// addComments appends the comments read from the file name since the
+// last call as an element.
+func (s *Section) addComments(name string, lines *Lines) {
+	line := lines.commentLine
+	if c := lines.takeComments(); len(c) > 0 {
+		s.add(Comment{Lines: c}, Pos{name, line})
+	}
+}
+
+func trimRight(s string) string {
+	return strings.TrimRightFunc(s, unicode.IsSpace)
+}
+
 var isHeading = regexp.MustCompile(`^\*+ `)
 
 func lesserHeading(text, prefix string) bool {
 	return isHeading.MatchString(text) && !strings.HasPrefix(text, prefix+"*")
 }
 
-func parseHeader(doc *Doc, lines *Lines) error {
+// parseHeader parses the title, subtitle and header lines of a document.
+// It returns an error if the header is incomplete and adds other problems
+// to errs.
+func parseHeader(doc *Doc, name string, lines *Lines, errs *ErrorList) *ParseError {
 	var ok bool
 	doc.Title, ok = lines.nextNonEmpty()
 	if !ok {
-		return fmt.Errorf("unexpected EOF; expected title")
+		return errorf(name, len(lines.text), 0, CodeUnexpectedEOF, "unexpected EOF; expected title")
 	}
+	doc.Title = trimRight(doc.Title)
 	//逐行读取，空行跳出，isSpeakerNote跳过，处理有前缀的"Tags:"，解析时间，最后处理子标题
 	for {
 		text, ok := lines.next()
 		if !ok {
-			return errors.New("unexpect EOF")
+			return errorf(name, len(lines.text), 0, CodeUnexpectedEOF, "unexpected EOF; expected a blank line after the header")
 		}
 		if len(text) == 0 {
 			break
@@ -344,41 +708,130 @@ func parseHeader(doc *Doc, lines *Lines) error {
 		if isSpeakerNote(text) {
 			continue
 		}
-		const tagPrefix = "Tags:"
+		const (
+			tagPrefix      = "Tags:"
+			orderPrefix    = "Order:"
+			requiresPrefix = "Requires:"
+			levelPrefix    = "Level:"
+			summaryPrefix  = "Summary:"
+			oldURLPrefix   = "OldURL:"
+			langPrefix     = "Lang:"
+			draftPrefix    = "Draft:"
+		)
 		if strings.HasPrefix(text, tagPrefix) {
 			tags := strings.Split(text[len(tagPrefix):], ",")
 			for i, _ := range tags {
 				tags[i] = strings.TrimSpace(tags[i])
 			}
 			doc.Tags = append(doc.Tags, tags...)
+		} else if strings.HasPrefix(text, orderPrefix) {
+			n, err := strconv.Atoi(strings.TrimSpace(text[len(orderPrefix):]))
+			if err != nil || n < 1 {
+				errs.Add(errorf(name, lines.line, valueColumn(text, orderPrefix), CodeBadHeader,
+					"bad order %q: must be a positive number", text))
+				continue
+			}
+			doc.Order = n
+		} else if strings.HasPrefix(text, requiresPrefix) {
+			for _, r := range strings.Split(text[len(requiresPrefix):], ",") {
+				if r = strings.TrimSpace(r); r != "" {
+					doc.Requires = append(doc.Requires, r)
+				}
+			}
+		} else if strings.HasPrefix(text, levelPrefix) {
+			doc.Level = strings.TrimSpace(text[len(levelPrefix):])
+		} else if strings.HasPrefix(text, summaryPrefix) {
+			doc.Summary = strings.TrimSpace(text[len(summaryPrefix):])
+		} else if strings.HasPrefix(text, oldURLPrefix) {
+			if u := strings.TrimSpace(text[len(oldURLPrefix):]); u != "" {
+				doc.OldURL = append(doc.OldURL, u)
+			}
+		} else if strings.HasPrefix(text, langPrefix) {
+			doc.Lang = strings.TrimSpace(text[len(langPrefix):])
+		} else if strings.HasPrefix(text, draftPrefix) {
+			v := strings.TrimSpace(text[len(draftPrefix):])
+			draft, err := strconv.ParseBool(v)
+			if v == "" {
+				draft, err = true, nil
+			}
+			if err != nil {
+				errs.Add(errorf(name, lines.line, valueColumn(text, draftPrefix), CodeBadHeader,
+					"bad draft %q: must be true or false", text))
+				continue
+			}
+			doc.Draft = draft
 		} else if t, ok := parseTime(text); ok {
 			doc.Time = t
+		} else if m := metaRE.FindStringSubmatch(text); m != nil {
+			if doc.Meta == nil {
+				doc.Meta = make(map[string]string)
+			}
+			doc.Meta[m[1]] = strings.TrimSpace(m[2])
 		} else if doc.Subtitle == "" {
-			doc.Subtitle = text
+			doc.Subtitle = trimRight(text)
 		} else {
-			return fmt.Errorf("unexpected header line: %q", text)
+			errs.Add(errorf(name, lines.line, 0, CodeBadHeader, "unexpected header line: %q", text))
 		}
 	}
 	return nil
 }
 
+// valueColumn returns the column of the value following prefix in text,
+// or of the first non-space character if prefix is "".
+func valueColumn(text, prefix string) int {
+	v := text[len(prefix):]
+	return len(prefix) + len(v) - len(strings.TrimLeftFunc(v, unicode.IsSpace)) + 1
+}
+
+// metaRE matches a "Meta-Key: value" header line. Free-form keys need the
+// prefix so that a subtitle such as "Concurrency: a tour" stays a subtitle.
+var metaRE = regexp.MustCompile(`^Meta-([A-Za-z0-9][A-Za-z0-9-]*):(?:\s+(.*))?$`)
+
+// timeLayouts are the layouts of the times parseTime accepts, with a time
+// of day. The zone, if given, is kept.
+var timeLayouts = []string{
+	"15:04 2 Jan 2006",
+	"15:04 2 Jan 2006 -0700",
+	"15:04 2 Jan 2006 MST",
+	time.RFC3339,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+// dateLayouts are the layouts of the dates parseTime accepts.
+var dateLayouts = []string{
+	"2 Jan 2006",
+	"2006-01-02",
+}
+
 func parseTime(s string) (t time.Time, ok bool) {
-	t, err := time.Parse("15:04 2 Jan 2006", s)
-	if err == nil {
-		return t, true
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
 	}
-	t, err = time.Parse("2 Jan 2006", s)
-	if err == nil {
-		// at 11am UTC it is the same date everywhere
-		t = t.Add(time.Hour * 11)
-		return t, true
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			// at 11am UTC it is the same date everywhere
+			return t.Add(time.Hour * 11), true
+		}
 	}
 	return
 }
 
 type Lines struct {
-	line int // 0 indexed, so has 1-indexed number of last line returned
-	text []string
+	line     int // 0 indexed, so has 1-indexed number of last line returned
+	text     []string
+	comment    string   // prefix of comment lines
+	commentEnd string   // suffix of comment lines, if they need one
+	comments   []string // text of the comment lines skipped and not yet taken
+
+	commentLine int // line number of the first of comments
 }
 
 func (l *Lines) back() {
@@ -407,15 +860,27 @@ func (l *Lines) next() (text string, ok bool) {
 			return "", false
 		}
 		text = l.text[current]
-		// '#'这个开头的是注释
-		if len(text) == 0 || text[0] != '#' {
+		// 以l.comment开头(并以l.commentEnd结尾)的是注释
+		t := trimRight(text)
+		if !strings.HasPrefix(t, l.comment) || !strings.HasSuffix(t[len(l.comment):], l.commentEnd) {
 			ok = true
 			break
 		}
+		if len(l.comments) == 0 {
+			l.commentLine = l.line
+		}
+		l.comments = append(l.comments, trimRight(t[len(l.comment):len(t)-len(l.commentEnd)]))
 	}
 	return
 }
 
+// takeComments returns the comment lines skipped since the last call.
+func (l *Lines) takeComments() []string {
+	c := l.comments
+	l.comments = nil
+	return c
+}
+
 func readLines(r io.Reader) (*Lines, error) {
 	var lines []string
 	scanner := bufio.NewScanner(r)
@@ -425,17 +890,48 @@ func readLines(r io.Reader) (*Lines, error) {
 	if err := scanner.Err(); err != nil {
 		return nil, err
 	}
-	return &Lines{0, lines}, nil
+	return &Lines{text: lines, comment: "#"}, nil
 }
 
 // renderElem implements the elem template function, used to render
 // sub-templates.
-func renderElem() string {
-	return ""
+func renderElem(t *template.Template, e Elem) (template.HTML, error) {
+	switch e.(type) {
+	case Comment, Include:
+		return "", nil
+	}
+	var data interface{} = e
+	if s, ok := e.(Section); ok {
+		data = struct {
+			Section
+			Template *template.Template
+		}{s, t}
+	}
+	return execTemplate(t, e.TemplateName(), data)
+}
+
+// pageNum derives a page number from a section.
+func pageNum(s Section, offset int) int {
+	if len(s.Number) == 0 {
+		return offset
+	}
+	return s.Number[0] + offset
 }
 
 func init() {
 	funcs["elem"] = renderElem
+	funcs["pagenum"] = pageNum
+}
+
+// execTemplate is a helper to execute a template and return the output as a
+// template.HTML value.
+func execTemplate(t *template.Template, name string, data interface{}) (template.HTML, error) {
+	b := new(bytes.Buffer)
+	err := t.ExecuteTemplate(b, name, data)
+	if err != nil {
+		return "", err
+	}
+	return template.HTML(b.String()), nil
 }
 
 func isSpeakerNote(s string) bool {
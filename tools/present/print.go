@@ -0,0 +1,292 @@
+package present
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Print writes doc to w as present source in canonical form: elements
+// separated by single blank lines, preformatted text indented with tabs,
+// and no trailing spaces. Comments and speaker notes are kept, though
+// comments within a block of text are moved after it.
+//
+// Parsing the output gives back doc, provided doc itself came from Parse.
+func Print(w io.Writer, doc *Doc) error {
+	p := &printer{}
+	p.header(doc)
+	for _, s := range doc.Sections {
+		if err := p.section(s); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write(append(bytes.TrimRight(p.buf.Bytes(), "\n"), '\n'))
+	return err
+}
+
+type printer struct {
+	buf bytes.Buffer
+}
+
+func (p *printer) line(s string) {
+	p.buf.WriteString(s)
+	p.buf.WriteByte('\n')
+}
+
+func (p *printer) lines(prefix string, lines []string) {
+	for _, l := range lines {
+		p.line(prefix + l)
+	}
+}
+
+// header prints everything before the first section.
+func (p *printer) header(doc *Doc) {
+	p.lines("#", doc.Comments)
+	p.line(doc.Title)
+	if doc.Subtitle != "" {
+		p.line(doc.Subtitle)
+	}
+	if !doc.Time.IsZero() {
+		p.line(formatTime(doc.Time))
+	}
+	if len(doc.Tags) > 0 {
+		p.line("Tags: " + strings.Join(doc.Tags, ", "))
+	}
+	if doc.Summary != "" {
+		p.line("Summary: " + doc.Summary)
+	}
+	for _, u := range doc.OldURL {
+		p.line("OldURL: " + u)
+	}
+	if doc.Lang != "" {
+		p.line("Lang: " + doc.Lang)
+	}
+	if doc.Draft {
+		p.line("Draft: true")
+	}
+	keys := make([]string, 0, len(doc.Meta))
+	for k := range doc.Meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		p.line(trimRight("Meta-" + k + ": " + doc.Meta[k]))
+	}
+	if doc.Order > 0 {
+		p.line(fmt.Sprintf("Order: %d", doc.Order))
+	}
+	if len(doc.Requires) > 0 {
+		p.line("Requires: " + strings.Join(doc.Requires, ", "))
+	}
+	if doc.Level != "" {
+		p.line("Level: " + doc.Level)
+	}
+	p.lines(": ", doc.TitleNotes)
+	p.line("")
+
+	for _, a := range doc.Authors {
+		// The affiliation has no element; it goes after the text.
+		affiliation := a.Affiliation != ""
+		for _, e := range a.Elem {
+			switch e := e.(type) {
+			case Link:
+				if affiliation {
+					p.line("Affiliation: " + a.Affiliation)
+					affiliation = false
+				}
+				if e.Cmd != "" {
+					p.line(e.Cmd)
+				} else {
+					p.line(e.Label)
+				}
+			case Text:
+				p.lines("", e.Lines)
+			}
+		}
+		if affiliation {
+			p.line("Affiliation: " + a.Affiliation)
+		}
+		p.line("")
+	}
+}
+
+func (p *printer) section(s Section) error {
+	p.line(strings.Repeat("*", len(s.Number)) + " " + s.Title)
+	p.line("")
+	for _, style := range s.Styles {
+		if url := strings.TrimPrefix(style, "background-image: url('"); url != style {
+			p.line(".background " + strings.TrimSuffix(url, "')"))
+			p.line("")
+		}
+	}
+
+	// Notes must come before the subsections, or they would be read
+	// back as notes of the last subsection.
+	notes := s.Notes
+	printNotes := func() {
+		if len(notes) > 0 {
+			p.lines(": ", notes)
+			p.line("")
+			notes = nil
+		}
+	}
+	for _, e := range s.Elem {
+		if sub, ok := e.(Section); ok {
+			if sub.Pos.File != s.Pos.File {
+				// Spliced in by an .include, which is printed instead.
+				continue
+			}
+			printNotes()
+			if err := p.section(sub); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := p.elem(e); err != nil {
+			return err
+		}
+		p.line("")
+	}
+	printNotes()
+	return nil
+}
+
+func (p *printer) elem(e Elem) error {
+	switch e := e.(type) {
+	case Text:
+		if e.Pre {
+			for _, l := range strings.Split(e.Lines[0], "\n") {
+				if l != "" {
+					l = "\t" + indentTabs(l)
+				}
+				p.line(l)
+			}
+			return nil
+		}
+		for _, l := range e.Lines {
+			if strings.HasPrefix(l, ".") || strings.HasPrefix(l, `\.`) {
+				l = `\` + l
+			}
+			p.line(l)
+		}
+	case List:
+		p.list(e, "")
+	case Table:
+		if e.Header != nil {
+			p.row(e.Header)
+			sep := make([]string, len(e.Align))
+			for i, a := range e.Align {
+				switch a {
+				case "left":
+					sep[i] = ":--"
+				case "right":
+					sep[i] = "--:"
+				case "center":
+					sep[i] = ":-:"
+				default:
+					sep[i] = "---"
+				}
+			}
+			p.line("|" + strings.Join(sep, "|") + "|")
+		}
+		for _, r := range e.Rows {
+			p.row(r)
+		}
+	case Comment:
+		p.lines("#", e.Lines)
+	case Code:
+		if e.Cmd == "" {
+			return fmt.Errorf("present: cannot print code given inline")
+		}
+		p.line(e.Cmd)
+	case Image:
+		p.line(e.Cmd)
+	case Link:
+		p.line(e.Cmd)
+	case Include:
+		p.line(e.Cmd)
+	case HTML:
+		p.line(e.Cmd)
+	case Iframe:
+		p.line(e.Cmd)
+	case Video:
+		p.line(e.Cmd)
+	case Caption:
+		p.line(e.Cmd)
+	case Math:
+		p.line(e.Cmd)
+		p.block(e.Body)
+	case Diagram:
+		p.line(e.Cmd)
+		p.block(e.Body)
+	case Quiz:
+		p.line(e.Cmd)
+		p.block(e.Body)
+	default:
+		return fmt.Errorf("present: cannot print %s element", e.TemplateName())
+	}
+	return nil
+}
+
+// block prints the body of a block command, indented by a tab.
+func (p *printer) block(body []string) {
+	for _, l := range body {
+		if l == "" {
+			p.line("")
+		} else {
+			p.line("\t" + l)
+		}
+	}
+}
+
+// formatTime returns t in the shortest form parseTime reads back as t.
+func formatTime(t time.Time) string {
+	name, offset := t.Zone()
+	utc := offset == 0 && (name == "UTC" || name == "")
+	switch {
+	case t.Second() != 0 || t.Nanosecond() != 0:
+		return t.Format(time.RFC3339Nano)
+	case !utc:
+		return t.Format("15:04 2 Jan 2006 -0700")
+	case t.Hour() == 11 && t.Minute() == 0:
+		// parseTime puts dates without a time at 11am.
+		return t.Format("2 Jan 2006")
+	}
+	return t.Format("15:04 2 Jan 2006")
+}
+
+// list prints l with its items indented by indent.
+func (p *printer) list(l List, indent string) {
+	for i, it := range l.Items {
+		marker := "- "
+		if l.Ordered {
+			marker = fmt.Sprintf("%d. ", l.Start+i)
+		}
+		p.line(indent + marker + it.Text)
+		if it.List != nil {
+			p.list(*it.List, indent+strings.Repeat(" ", len(marker)))
+		}
+	}
+}
+
+// row prints a row of a table.
+func (p *printer) row(cells []string) {
+	escaped := make([]string, len(cells))
+	for i, c := range cells {
+		escaped[i] = strings.Replace(c, "|", `\|`, -1)
+	}
+	p.line(strings.TrimRight("| "+strings.Join(escaped, " | ")+" |", " "))
+}
+
+// indentTabs turns the leading spaces of a line of preformatted text back
+// into the tabs parseSections replaced with four spaces each.
+func indentTabs(l string) string {
+	n := 0
+	for strings.HasPrefix(l[n:], "    ") {
+		n += 4
+	}
+	return strings.Repeat("\t", n/4) + l[n:]
+}
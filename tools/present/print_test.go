@@ -0,0 +1,261 @@
+package present
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testFiles = map[string]string{
+	"hello.go":      "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hello\") // HLx\n}\n",
+	"fragment.html": `<p onclick="x()">Hi <a href="javascript:x()">there</a><script>x()</script><em>unclosed`,
+}
+
+func testContext() *Context {
+	return &Context{ReadFile: func(name string) ([]byte, error) {
+		if b, ok := testFiles[filepath.Base(name)]; ok {
+			return []byte(b), nil
+		}
+		return nil, os.ErrNotExist
+	}}
+}
+
+var printTests = []struct {
+	name string
+	in   string
+	out  string // canonical form; empty if the input already is
+}{
+	{
+		name: "header",
+		in: `# Copyright notice.
+Title
+Subtitle
+15:04 2 Jan 2015
+Tags: go, present
+Order: 2
+Requires: basics, flowcontrol
+Level: beginner
+: a note on the title
+
+Author Name
+Job title
+https://example.com/
+@gopher
+gopher@example.com
+
+* Intro
+
+Text.
+`,
+	},
+	{
+		name: "date only",
+		in: `Title
+2 Jan 2015
+
+* Intro
+
+Text.
+`,
+	},
+	{
+		name: "metadata",
+		in: `Title
+2024-05-01T09:30:00+08:00
+Summary: What's new.
+OldURL: /old/path
+Lang: zh
+Draft:
+Meta-Slug: whats-new
+
+* Intro
+
+Text.
+`,
+		out: `Title
+09:30 1 May 2024 +0800
+Summary: What's new.
+OldURL: /old/path
+Lang: zh
+Draft: true
+Meta-Slug: whats-new
+
+* Intro
+
+Text.
+`,
+	},
+	{
+		name: "messy",
+		in: "Title   \n\nAuthor  \n\n\n*   Intro  \n\n\nSome text  \nover two lines.\n" +
+			"\\.not a command\n\n\n  \tcode\n  \t\tindented\n\n  \tmore\n\n- one  \n- two\n" +
+			"# a comment  \n\n: note  \n.code   hello.go  \n.play hello.go HLx\n" +
+			".background bg.png\n.image gopher.png 100 _\n.link https://go.dev Go  \n" +
+			"** Sub\n\nSub text.\n#trailing comment\n",
+		out: `Title
+
+Author
+
+* Intro
+
+.background bg.png
+
+Some text
+over two lines.
+\.not a command
+
+	code
+		indented
+
+	more
+
+- one
+- two
+
+# a comment
+
+.code   hello.go
+
+.play hello.go HLx
+
+.image gopher.png 100 _
+
+.link https://go.dev Go
+
+: note
+
+** Sub
+
+Sub text.
+
+#trailing comment
+`,
+	},
+	{
+		name: "escapes",
+		in: `Title
+
+* Escapes
+
+Text
+\.starts with a dot
+\\.starts with a backslash
+
+: first note
+: second note
+
+** Sub one
+
+# comment before sub two
+
+** Sub two
+
+- bullet
+`,
+	},
+}
+
+func TestPrintRoundTrip(t *testing.T) {
+	for _, tt := range printTests {
+		doc, err := testContext().Parse(strings.NewReader(tt.in), tt.name, 0)
+		if err != nil {
+			t.Errorf("%s: parsing input: %v", tt.name, err)
+			continue
+		}
+		var buf bytes.Buffer
+		if err := Print(&buf, doc); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		want := tt.out
+		if want == "" {
+			want = tt.in
+		}
+		if got := buf.String(); got != want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, got, want)
+		}
+		checkRoundTrip(t, testContext(), tt.name, doc)
+	}
+}
+
+// TestPrintTour checks that the tour's lessons survive a round trip.
+func TestPrintTour(t *testing.T) {
+	files, err := filepath.Glob("../../tour/content/*.article")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Skip("no tour lessons found")
+	}
+	ctx := &Context{ReadFile: ioutil.ReadFile}
+	for _, file := range files {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc, err := ctx.Parse(bytes.NewReader(b), file, 0)
+		if err != nil {
+			t.Errorf("%s: %v", file, err)
+			continue
+		}
+		checkRoundTrip(t, ctx, file, doc)
+	}
+}
+
+// checkRoundTrip checks that parsing the printed form of doc gives doc,
+// and that printing is then stable.
+func checkRoundTrip(t *testing.T, ctx *Context, name string, doc *Doc) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Print(&buf, doc); err != nil {
+		t.Errorf("%s: %v", name, err)
+		return
+	}
+	printed := buf.String()
+	doc2, err := ctx.Parse(strings.NewReader(printed), name, 0)
+	if err != nil {
+		t.Errorf("%s: parsing printed document: %v\n%s", name, err, printed)
+		return
+	}
+	if !reflect.DeepEqual(clearPos(doc), clearPos(doc2)) {
+		t.Errorf("%s: parse(print(doc)) != doc\nprinted:\n%s", name, printed)
+		return
+	}
+	buf.Reset()
+	if err := Print(&buf, doc2); err != nil {
+		t.Errorf("%s: %v", name, err)
+		return
+	}
+	if buf.String() != printed {
+		t.Errorf("%s: printing is not stable:\n%s\nthen\n%s", name, printed, buf.String())
+	}
+}
+
+// clearPos returns a copy of doc without source positions, which change
+// when a document is reformatted.
+func clearPos(doc *Doc) *Doc {
+	d := *doc
+	d.Sections = clearSectionPos(doc.Sections)
+	return &d
+}
+
+func clearSectionPos(sections []Section) []Section {
+	var out []Section
+	for _, s := range sections {
+		s.Pos, s.ElemPos = Pos{}, nil
+		elems := make([]Elem, len(s.Elem))
+		for i, e := range s.Elem {
+			if sub, ok := e.(Section); ok {
+				e = clearSectionPos([]Section{sub})[0]
+			}
+			elems[i] = e
+		}
+		s.Elem = elems
+		out = append(out, s)
+	}
+	return out
+}
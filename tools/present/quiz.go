@@ -0,0 +1,133 @@
+package present
+
+import (
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strings"
+)
+
+func init() {
+	RegisterCommand(&Command{
+		Name:  "quiz",
+		Doc:   "Asks a multiple choice question written in the indented block below.",
+		Block: true,
+		Args:  []Arg{{Name: "id", Optional: true, Doc: "names the quiz in recorded answers; by default a hash of the question"}},
+		Parse: parseQuiz,
+	})
+}
+
+/*
+	A quiz is a question with lettered choices, the key of the right one
+	and an explanation shown once the reader has answered:
+
+		.quiz
+			Question: What is len("héllo")?
+			a) 5
+			b) 6
+			c) It doesn't compile.
+			Answer: b
+			Explanation: len counts bytes, and é takes two in UTF-8.
+
+	A line without a prefix continues the line before it. The parser
+	checks the question and the choices; lint checks that there is
+	exactly one answer and that it names a choice.
+*/
+
+// Quiz is a multiple choice question.
+type Quiz struct {
+	Cmd         string   // original command from present source
+	Body        []string // indented lines following the command
+	ID          string
+	Question    string
+	Choices     []Choice
+	Answers     []string // keys given after Answer:; a valid quiz has one
+	Explanation string
+}
+
+// Choice is one of the choices of a quiz.
+type Choice struct {
+	Key  string
+	Text string
+}
+
+func (q Quiz) TemplateName() string { return "quiz" }
+
+// Answer returns the key of the right choice, or "" if the quiz doesn't
+// have exactly one answer naming a choice.
+func (q Quiz) Answer() string {
+	if len(q.Answers) != 1 {
+		return ""
+	}
+	if _, ok := q.Choice(q.Answers[0]); !ok {
+		return ""
+	}
+	return q.Answers[0]
+}
+
+// Choice returns the choice with the given key.
+func (q Quiz) Choice(key string) (Choice, bool) {
+	for _, c := range q.Choices {
+		if c.Key == key {
+			return c, true
+		}
+	}
+	return Choice{}, false
+}
+
+var quizChoiceRE = regexp.MustCompile(`^([A-Za-z0-9])\)\s+(.*)$`)
+
+func parseQuiz(ctx *Context, c *CommandLine) (Elem, error) {
+	q := Quiz{Cmd: c.Cmd, Body: c.Body, ID: c.String("id")}
+	var last *string // the field a line without a prefix continues
+	for i, line := range c.Body {
+		line = strings.TrimSpace(line)
+		lineErr := func(format string, args ...interface{}) error {
+			return errorf(c.File, c.Line+1+i, valueColumn(c.Body[i], ""), CodeBadCommand, "quiz: %s", fmt.Sprintf(format, args...))
+		}
+		switch {
+		case line == "":
+			last = nil
+		case strings.HasPrefix(line, "Question:"):
+			if q.Question != "" {
+				return nil, lineErr("more than one question")
+			}
+			q.Question = strings.TrimSpace(strings.TrimPrefix(line, "Question:"))
+			last = &q.Question
+		case strings.HasPrefix(line, "Answer:"):
+			q.Answers = append(q.Answers, strings.FieldsFunc(strings.TrimPrefix(line, "Answer:"), func(r rune) bool {
+				return r == ',' || r == ' ' || r == '\t'
+			})...)
+			last = nil
+		case strings.HasPrefix(line, "Explanation:"):
+			if q.Explanation != "" {
+				return nil, lineErr("more than one explanation")
+			}
+			q.Explanation = strings.TrimSpace(strings.TrimPrefix(line, "Explanation:"))
+			last = &q.Explanation
+		case quizChoiceRE.MatchString(line):
+			m := quizChoiceRE.FindStringSubmatch(line)
+			if _, ok := q.Choice(m[1]); ok {
+				return nil, lineErr("choice %s) given twice", m[1])
+			}
+			q.Choices = append(q.Choices, Choice{Key: m[1], Text: m[2]})
+			last = &q.Choices[len(q.Choices)-1].Text
+		case last != nil:
+			*last += " " + line
+		default:
+			return nil, lineErr("unexpected line %q; want Question:, a choice such as a) text, Answer: or Explanation:", line)
+		}
+	}
+	switch {
+	case q.Question == "":
+		return nil, c.Errorf("", "quiz has no Question: line")
+	case len(q.Choices) < 2:
+		return nil, c.Errorf("", "quiz has %d choices; want at least 2", len(q.Choices))
+	}
+	if q.ID == "" {
+		h := fnv.New32a()
+		h.Write([]byte(q.Question))
+		q.ID = fmt.Sprintf("%08x", h.Sum32())
+	}
+	return q, nil
+}
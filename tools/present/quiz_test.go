@@ -0,0 +1,67 @@
+package present
+
+import (
+	"strings"
+	"testing"
+)
+
+const quizSrc = `Title
+
+* Quiz
+
+.quiz len
+	Question: What is
+	len("héllo")?
+	a) 5
+	b) 6
+
+	c) It doesn't compile.
+	Answer: b
+	Explanation: len counts bytes.
+`
+
+func TestQuiz(t *testing.T) {
+	doc, err := testContext().Parse(strings.NewReader(quizSrc), "doc.slide", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := doc.Sections[0].Elem[0].(Quiz)
+	if q.ID != "len" || q.Question != `What is len("héllo")?` || len(q.Choices) != 3 || q.Answer() != "b" || q.Explanation != "len counts bytes." {
+		t.Errorf("got %+v", q)
+	}
+	checkRoundTrip(t, testContext(), "doc.slide", doc)
+
+	_, err = testContext().Parse(strings.NewReader("T\n\n* S\n\n.quiz\n\tQuestion: Why?\n\ta) Because.\n"), "doc.slide", 0)
+	if err == nil || !strings.Contains(err.Error(), "want at least 2") {
+		t.Errorf("one choice: got %v", err)
+	}
+	_, err = testContext().Parse(strings.NewReader("T\n\n* S\n\n.quiz\n\tQuestion: Why?\n\ta) Yes.\n\ta) No.\n"), "doc.slide", 0)
+	if err == nil || !strings.Contains(err.Error(), "doc.slide:8:") {
+		t.Errorf("repeated choice: got %v, want it on line 8", err)
+	}
+}
+
+func TestLintQuiz(t *testing.T) {
+	for _, tt := range []struct {
+		answer string
+		line   int
+		msg    string
+	}{
+		{"Answer: b", 0, ""},
+		{"", 5, "no answer"},
+		{"Answer: d", 12, "names no choice"},
+		{"Answer: a, b", 12, "2 answers"},
+	} {
+		src := strings.Replace(quizSrc, "Answer: b", tt.answer, 1)
+		errs := testContext().Lint(strings.NewReader(src), "doc.slide", LintOptions{})
+		if tt.msg == "" {
+			if len(errs) > 0 {
+				t.Errorf("%q: got %v", tt.answer, errs)
+			}
+			continue
+		}
+		if len(errs) != 1 || errs[0].Code != CodeQuizAnswer || errs[0].Line != tt.line || !strings.Contains(errs[0].Msg, tt.msg) {
+			t.Errorf("%q: got %v, want %s on line %d", tt.answer, errs, tt.msg, tt.line)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package present
+
+import (
+	"regexp"
+	"strings"
+)
+
+// A Table is a block of rows written between '|' characters:
+//
+//	| Type    | Size |
+//	|:--------|-----:|
+//	| int32   |    4 |
+//	| float64 |    8 |
+//
+// A row of dashes under the first row makes it the header; colons on its
+// left, right or both sides align the column left, right or centre. A '|'
+// inside a cell is written as `\|`. Cells hold inline markup.
+type Table struct {
+	Header []string // cells of the header row; nil if there is none
+	Align  []string // "left", "right", "center" or "" for each column, if there is a header
+	Rows   [][]string
+}
+
+func (t Table) TemplateName() string { return "table" }
+
+// AlignOf returns the alignment of column i.
+func (t Table) AlignOf(i int) string {
+	if i < len(t.Align) {
+		return t.Align[i]
+	}
+	return ""
+}
+
+var tableSepRE = regexp.MustCompile(`^\|?(\s*:?-+:?\s*\|)*\s*:?-+:?\s*\|?$`)
+
+// isTableRow reports whether text is a row of a table.
+func isTableRow(text string) bool {
+	return strings.HasPrefix(text, "|")
+}
+
+// parseTable parses the table starting with the line text. The cells are
+// passed through inline, if not nil, to turn them into present markup.
+func parseTable(text string, lines *Lines, inline func(string) string) Elem {
+	var rows [][]string
+	var t Table
+	ok := true
+	for ok && isTableRow(text) {
+		if len(rows) == 1 && t.Header == nil && tableSepRE.MatchString(trimRight(text)) {
+			t.Header = rows[0]
+			rows = nil
+			for _, c := range splitRow(text) {
+				t.Align = append(t.Align, columnAlign(strings.TrimSpace(c)))
+			}
+		} else {
+			rows = append(rows, splitRow(text))
+		}
+		text, ok = lines.next()
+	}
+	lines.back()
+
+	n := len(t.Header)
+	for _, r := range rows {
+		if len(r) > n {
+			n = len(r)
+		}
+	}
+	pad := func(cells []string) []string {
+		for i := range cells {
+			if inline != nil {
+				cells[i] = inline(cells[i])
+			}
+		}
+		for len(cells) < n {
+			cells = append(cells, "")
+		}
+		return cells
+	}
+	if t.Header != nil {
+		t.Header = pad(t.Header)
+		for len(t.Align) < n {
+			t.Align = append(t.Align, "")
+		}
+	}
+	for _, r := range rows {
+		t.Rows = append(t.Rows, pad(r))
+	}
+	return t
+}
+
+// splitRow returns the trimmed cells of a table row.
+func splitRow(text string) []string {
+	text = strings.TrimSpace(text)
+	text = strings.TrimPrefix(text, "|")
+	if strings.HasSuffix(text, "|") && !strings.HasSuffix(text, `\|`) {
+		text = text[:len(text)-1]
+	}
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(text); i++ {
+		switch {
+		case text[i] == '\\' && i+1 < len(text) && text[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case text[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(text[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+func columnAlign(sep string) string {
+	left, right := strings.HasPrefix(sep, ":"), strings.HasSuffix(sep, ":")
+	switch {
+	case left && right:
+		return "center"
+	case left:
+		return "left"
+	case right:
+		return "right"
+	}
+	return ""
+}
@@ -0,0 +1,46 @@
+package present
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTable(t *testing.T) {
+	const src = `Title
+
+* Sizes
+
+| Type | Size | Note |
+|:-----|-----:|:----:|
+| *int32* | 4 | a \| b
+| 整数 |
+`
+	doc, err := testContext().Parse(strings.NewReader(src), "doc.article", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := doc.Sections[0].Elem[0]
+	want := Table{
+		Header: []string{"Type", "Size", "Note"},
+		Align:  []string{"left", "right", "center"},
+		Rows: [][]string{
+			{"*int32*", "4", "a | b"},
+			{"整数", "", ""},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	checkRoundTrip(t, testContext(), "doc.article", doc)
+
+	md := "# Title\n\n## Sizes\n\n| **a** | `b c` |\n| 1 | 2 |\n"
+	doc, err = testContext().Parse(strings.NewReader(md), "doc.md", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = Table{Rows: [][]string{{"*a*", "`b`c`"}, {"1", "2"}}}
+	if got := doc.Sections[0].Elem[0]; !reflect.DeepEqual(got, want) {
+		t.Errorf("Markdown: got %+v, want %+v", got, want)
+	}
+}
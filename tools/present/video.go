@@ -0,0 +1,42 @@
+package present
+
+import "regexp"
+
+func init() {
+	RegisterCommand(&Command{
+		Name: "video",
+		Doc:  "Plays a video file.",
+		Args: []Arg{
+			{Name: "file", Type: URLArg, Doc: "the video"},
+			{Name: "type", Type: StringArg, Doc: "its MIME type, such as video/mp4"},
+			{Name: "width", Type: IntArg, Optional: true, Doc: "the width in pixels"},
+			{Name: "height", Type: IntArg, Paired: true, Doc: "the height in pixels"},
+		},
+		Parse: parseVideo,
+	})
+}
+
+// Video plays a video file:
+//
+//	.video gopher.mp4 video/mp4 640 360
+//
+// The type is the MIME type of the file; the width and height are
+// optional, as for .iframe.
+type Video struct {
+	Cmd        string // original command from present source
+	URL        string
+	SourceType string
+	Width      int
+	Height     int
+}
+
+func (v Video) TemplateName() string { return "video" }
+
+var mimeTypeRE = regexp.MustCompile(`^(video|audio)/[a-z0-9][a-z0-9.+-]*$`)
+
+func parseVideo(ctx *Context, c *CommandLine) (Elem, error) {
+	if !mimeTypeRE.MatchString(c.String("type")) {
+		return nil, c.Errorf("type", "bad video type %q: must be a MIME type such as video/mp4", c.String("type"))
+	}
+	return Video{Cmd: c.Cmd, URL: c.String("file"), SourceType: c.String("type"), Width: c.Int("width"), Height: c.Int("height")}, nil
+}
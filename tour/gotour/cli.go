@@ -0,0 +1,430 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/Tobecoder/go/tools/present"
+)
+
+const cliHelp = `Commands:
+  list            list the lessons
+  open LESSON [N] open page N (default 1) of a lesson
+  next, prev      go to the next or previous page
+  page N          go to page N of the current lesson
+  show            show the current page again
+  edit [FILE]     edit the page's program with $EDITOR
+  run [FILE]      build and run the page's program
+  reset [FILE]    discard your changes to the page's program
+  answer [N] KEY  answer quiz N (default 1) of the page with choice KEY
+  help            show this help
+  quit            leave the tour
+`
+
+// cli is the terminal front end of the tour.
+type cli struct {
+	root    string
+	dir     string // directory of the state files
+	lang    string // language of the lessons
+	lessons []*cliLesson
+	text    *textRenderer
+	in      *bufio.Scanner
+	out     io.Writer
+
+	prog progress
+}
+
+type cliLesson struct {
+	name string
+	doc  *present.Doc
+}
+
+// runCLI runs the terminal tour with the command line arguments args.
+func runCLI(root string, args []string) error {
+	fs := flag.NewFlagSet("cli", flag.ExitOnError)
+	width := fs.Int("width", 80, "wrap text at this many columns")
+	fs.Parse(args)
+
+	dir, err := stateDir()
+	if err != nil {
+		return err
+	}
+	c := &cli{
+		root: root,
+		dir:  dir,
+		text: &textRenderer{w: os.Stdout, width: *width},
+		in:   bufio.NewScanner(os.Stdin),
+		out:  os.Stdout,
+	}
+	if err := c.load(); err != nil {
+		return err
+	}
+
+	buildDir, err = ioutil.TempDir("", "gotour-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(buildDir)
+
+	c.list()
+	if l := fs.Arg(0); l != "" {
+		c.open(l, 1)
+	} else if c.prog.Lesson != "" {
+		fmt.Fprintf(c.out, "Resuming %s at page %d.\n\n", c.prog.Lesson, c.prog.Page)
+		c.open(c.prog.Lesson, c.prog.Page)
+	}
+	return c.loop()
+}
+
+// load parses the lessons and the saved progress.
+func (c *cli) load() error {
+	present.PlayEnabled = true
+	content := filepath.Join(c.root, "content")
+	c.lang = *sourceLang
+	if lang := cliLang(content); lang != "" {
+		c.lang = lang
+		content = filepath.Join(content, lang)
+	}
+	files, err := lessonFiles(content)
+	if err != nil {
+		return err
+	}
+	docs := make(map[string]*present.Doc)
+	meta := make(map[string]*Lesson)
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		doc, err := present.Parse(f, file, 0)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("parsing %v: %v", file, err)
+		}
+		name := lessonName(file)
+		docs[name] = doc
+		meta[name] = &Lesson{Title: doc.Title, Order: doc.Order, Level: doc.Level, Requires: doc.Requires, Draft: doc.Draft}
+	}
+	if !*showDrafts {
+		hideDrafts(meta)
+	}
+
+	// Put the lessons in reading order.
+	graph, err := lessonGraph(meta)
+	if err != nil {
+		return err
+	}
+	for _, n := range graph {
+		c.lessons = append(c.lessons, &cliLesson{n.Name, docs[n.Name]})
+	}
+
+	c.prog, err = loadProgress(c.dir)
+	return err
+}
+
+// cliLang returns the translation directory under content matching the
+// LANG environment variable, or "" to use the source lessons.
+func cliLang(content string) string {
+	lang := strings.ToLower(os.Getenv("LANG"))
+	if i := strings.IndexAny(lang, ".@"); i >= 0 {
+		lang = lang[:i]
+	}
+	lang = strings.Replace(lang, "_", "-", -1)
+	for lang != "" && lang != *sourceLang {
+		if m, _ := lessonFiles(filepath.Join(content, lang)); len(m) > 0 {
+			return lang
+		}
+		i := strings.LastIndex(lang, "-")
+		if i < 0 {
+			break
+		}
+		lang = lang[:i]
+	}
+	return ""
+}
+
+func (c *cli) loop() error {
+	for {
+		fmt.Fprint(c.out, "tour> ")
+		if !c.in.Scan() {
+			fmt.Fprintln(c.out)
+			return c.in.Err()
+		}
+		args := strings.Fields(c.in.Text())
+		if len(args) == 0 {
+			continue
+		}
+		arg := func(i int) string {
+			if i < len(args) {
+				return args[i]
+			}
+			return ""
+		}
+		var err error
+		switch args[0] {
+		case "l", "list":
+			c.list()
+		case "o", "open":
+			n, _ := strconv.Atoi(arg(2))
+			if n == 0 {
+				n = 1
+			}
+			c.open(arg(1), n)
+		case "n", "next":
+			c.move(1)
+		case "p", "prev":
+			c.move(-1)
+		case "g", "page":
+			n, _ := strconv.Atoi(arg(1))
+			c.open(c.prog.Lesson, n)
+		case "s", "show":
+			c.open(c.prog.Lesson, c.prog.Page)
+		case "e", "edit":
+			err = c.edit(arg(1))
+		case "r", "run":
+			err = c.run(arg(1))
+		case "reset":
+			err = c.reset(arg(1))
+		case "a", "answer":
+			if len(args) > 2 {
+				n, _ := strconv.Atoi(arg(1))
+				err = c.answer(n, arg(2))
+			} else {
+				err = c.answer(1, arg(1))
+			}
+		case "h", "help", "?":
+			fmt.Fprint(c.out, cliHelp)
+		case "q", "quit", "exit":
+			return nil
+		default:
+			fmt.Fprintf(c.out, "unknown command %q; type help for a list\n", args[0])
+		}
+		if err != nil {
+			fmt.Fprintln(c.out, err)
+		}
+	}
+}
+
+// list prints the lessons and how far each has been read.
+func (c *cli) list() {
+	for _, l := range c.lessons {
+		mark := " "
+		if c.prog.Read[l.name] >= len(l.doc.Sections) {
+			mark = "*"
+		}
+		fmt.Fprintf(c.out, "%s %-14s %3d/%-3d %s\n", mark, l.name,
+			c.prog.Read[l.name], len(l.doc.Sections), l.doc.Title)
+	}
+	fmt.Fprintln(c.out)
+}
+
+func (c *cli) lesson(name string) *cliLesson {
+	for _, l := range c.lessons {
+		if l.name == name {
+			return l
+		}
+	}
+	return nil
+}
+
+// open shows page n of the named lesson and makes it the current page.
+func (c *cli) open(name string, n int) {
+	l := c.lesson(name)
+	if l == nil {
+		fmt.Fprintf(c.out, "no lesson %q\n", name)
+		return
+	}
+	if n < 1 || n > len(l.doc.Sections) {
+		fmt.Fprintf(c.out, "%s has pages 1 to %d\n", name, len(l.doc.Sections))
+		return
+	}
+	c.prog.Lesson, c.prog.Page = name, n
+	if n > c.prog.Read[name] {
+		c.prog.Read[name] = n
+	}
+	if err := c.prog.save(c.dir); err != nil {
+		fmt.Fprintln(c.out, err)
+	}
+
+	fmt.Fprintf(c.out, "%s (%d/%d)\n\n", l.doc.Title, n, len(l.doc.Sections))
+	c.text.quizzes = 0
+	c.text.section(l.doc.Sections[n-1])
+}
+
+// move goes delta pages forward or back, crossing into the neighbouring
+// lessons at either end.
+func (c *cli) move(delta int) {
+	for i, l := range c.lessons {
+		if l.name != c.prog.Lesson {
+			continue
+		}
+		n := c.prog.Page + delta
+		switch {
+		case n < 1 && i > 0:
+			prev := c.lessons[i-1]
+			c.open(prev.name, len(prev.doc.Sections))
+		case n > len(l.doc.Sections) && i+1 < len(c.lessons):
+			c.open(c.lessons[i+1].name, 1)
+		case n >= 1 && n <= len(l.doc.Sections):
+			c.open(l.name, n)
+		default:
+			fmt.Fprintln(c.out, "no more pages")
+		}
+		return
+	}
+	fmt.Fprintln(c.out, "no lesson open; use open LESSON")
+}
+
+// code returns the named program of the current page, or its first one
+// when name is empty, together with the path of the user's copy.
+func (c *cli) code(name string) (*present.Code, string, error) {
+	l := c.lesson(c.prog.Lesson)
+	if l == nil || c.prog.Page < 1 || c.prog.Page > len(l.doc.Sections) {
+		return nil, "", fmt.Errorf("no lesson open; use open LESSON")
+	}
+	codes := findPlayCode(l.doc.Sections[c.prog.Page-1])
+	for _, code := range codes {
+		if name == "" || code.FileName == name {
+			path := filepath.Join(c.dir, "snippets", l.name, strconv.Itoa(c.prog.Page), code.FileName)
+			return code, path, nil
+		}
+	}
+	if name == "" {
+		return nil, "", fmt.Errorf("this page has no program")
+	}
+	return nil, "", fmt.Errorf("this page has no program %q", name)
+}
+
+// edit opens the user's copy of a program in $EDITOR, creating it from
+// the lesson's version first.
+func (c *cli) edit(name string) error {
+	code, path, err := c.code(name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(path, code.Raw, 0644); err != nil {
+			return err
+		}
+	}
+	editor := strings.Fields(os.Getenv("EDITOR"))
+	if len(editor) == 0 {
+		editor = []string{"vi"}
+		if runtime.GOOS == "windows" {
+			editor = []string{"notepad"}
+		}
+	}
+	cmd := exec.Command(editor[0], append(editor[1:], path)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd.Run()
+}
+
+// run builds and runs a program the same way the server does, using the
+// user's copy if there is one. Interrupt stops the program.
+func (c *cli) run(name string) error {
+	code, path, err := c.code(name)
+	if err != nil {
+		return err
+	}
+	body, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		body = code.Raw
+	} else if err != nil {
+		return err
+	}
+
+	out := make(chan *Message)
+	procc := make(chan *process, 1)
+	go func() {
+		procc <- startProcess("cli", string(body), out, nil)
+	}()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	defer signal.Stop(sig)
+	killed := false
+	for {
+		select {
+		case m := <-out:
+			switch m.Kind {
+			case "stdout":
+				fmt.Fprint(c.out, m.Body)
+			case "stderr":
+				fmt.Fprint(os.Stderr, m.Body)
+			case "end":
+				if m.Body != "" {
+					fmt.Fprintf(c.out, "\nProgram exited: %s\n", m.Body)
+				} else {
+					fmt.Fprintln(c.out, "\nProgram exited.")
+				}
+				return nil
+			}
+		case <-sig:
+			if killed {
+				continue
+			}
+			killed = true
+			go func() {
+				(<-procc).Kill()
+			}()
+		}
+	}
+}
+
+// reset removes the user's copy of a program.
+func (c *cli) reset(name string) error {
+	_, path, err := c.code(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// answer answers quiz n of the current page with the choice key, and
+// shows whether it was right along with the explanation.
+func (c *cli) answer(n int, key string) error {
+	l := c.lesson(c.prog.Lesson)
+	if l == nil || c.prog.Page < 1 || c.prog.Page > len(l.doc.Sections) {
+		return fmt.Errorf("no lesson open; use open LESSON")
+	}
+	qs := findQuizzes(l.doc.Sections[c.prog.Page-1])
+	switch {
+	case len(qs) == 0:
+		return fmt.Errorf("this page has no quiz")
+	case n < 1 || n > len(qs):
+		return fmt.Errorf("this page has quizzes 1 to %d", len(qs))
+	}
+	q := qs[n-1]
+	a, err := c.prog.answer(c.lang, l.name, q, key)
+	if err != nil {
+		return err
+	}
+	if err := c.prog.save(c.dir); err != nil {
+		return err
+	}
+	if a.Correct {
+		fmt.Fprintln(c.out, "Right.")
+	} else {
+		fmt.Fprintf(c.out, "Wrong; the answer is %s.\n", q.Answer())
+	}
+	if q.Explanation != "" {
+		c.text.wrap(plainText(q.Explanation), "", "")
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// lessonNode is the JSON form of a lesson in the lesson graph.
+type lessonNode struct {
+	Name     string
+	Title    string
+	Level    string   `json:",omitempty"`
+	Requires []string `json:",omitempty"`
+	Prev     string   // lesson read before this one; "" for the first
+	Next     string   // lesson read after this one; "" for the last
+}
+
+// hideDrafts removes the lessons marked Draft from lessons, along with the
+// requirements on them, so that a published lesson may require a lesson
+// that is still being written.
+func hideDrafts(lessons map[string]*Lesson) {
+	drafts := make(map[string]bool)
+	for name, l := range lessons {
+		if l.Draft {
+			drafts[name] = true
+			delete(lessons, name)
+		}
+	}
+	for _, l := range lessons {
+		var requires []string
+		for _, r := range l.Requires {
+			if !drafts[r] {
+				requires = append(requires, r)
+			}
+		}
+		l.Requires = requires
+	}
+}
+
+// lessonGraph checks the prerequisites declared by the lessons and returns
+// them in reading order: every lesson comes after the lessons it requires,
+// and otherwise lessons are sorted by their Order header, then by name.
+// Lessons without an Order come after those with one.
+func lessonGraph(lessons map[string]*Lesson) ([]*lessonNode, error) {
+	var names []string
+	for name, l := range lessons {
+		for _, r := range l.Requires {
+			if _, ok := lessons[r]; !ok {
+				return nil, fmt.Errorf("lesson %q requires unknown lesson %q", name, r)
+			}
+		}
+		names = append(names, name)
+	}
+	before := func(a, b string) bool {
+		oa, ob := lessons[a].Order, lessons[b].Order
+		switch {
+		case oa == ob:
+			return a < b
+		case oa == 0:
+			return false
+		case ob == 0:
+			return true
+		}
+		return oa < ob
+	}
+
+	// Kahn's algorithm, always picking the first ready lesson.
+	pending := make(map[string]int)
+	dependents := make(map[string][]string)
+	for _, name := range names {
+		for _, r := range lessons[name].Requires {
+			pending[name]++
+			dependents[r] = append(dependents[r], name)
+		}
+	}
+	var ready, order []string
+	for _, name := range names {
+		if pending[name] == 0 {
+			ready = append(ready, name)
+		}
+	}
+	for len(ready) > 0 {
+		sort.Slice(ready, func(i, j int) bool { return before(ready[i], ready[j]) })
+		name := ready[0]
+		ready = ready[1:]
+		order = append(order, name)
+		for _, d := range dependents[name] {
+			if pending[d]--; pending[d] == 0 {
+				ready = append(ready, d)
+			}
+		}
+	}
+	if len(order) < len(names) {
+		return nil, fmt.Errorf("lesson prerequisites form a cycle: %s", findCycle(lessons, pending))
+	}
+
+	nodes := make([]*lessonNode, len(order))
+	for i, name := range order {
+		l := lessons[name]
+		nodes[i] = &lessonNode{Name: name, Title: l.Title, Level: l.Level, Requires: l.Requires}
+		if i > 0 {
+			nodes[i].Prev = order[i-1]
+			nodes[i-1].Next = name
+		}
+	}
+	return nodes, nil
+}
+
+// findCycle returns a description of a cycle among the lessons that
+// still have pending prerequisites, such as "a -> b -> a".
+func findCycle(lessons map[string]*Lesson, pending map[string]int) string {
+	var start string
+	for name, n := range pending {
+		if n > 0 && (start == "" || name < start) {
+			start = name
+		}
+	}
+	// Every stuck lesson has a stuck prerequisite, so walking them
+	// must eventually revisit a lesson.
+	seen := make(map[string]int)
+	var path []string
+	for name := start; ; {
+		if i, ok := seen[name]; ok {
+			return strings.Join(append(path[i:], name), " -> ")
+		}
+		seen[name] = len(path)
+		path = append(path, name)
+		for _, r := range lessons[name].Requires {
+			if pending[r] > 0 {
+				name = r
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLessonGraph(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		lessons map[string]*Lesson
+		want    []string
+	}{
+		{
+			"by name",
+			map[string]*Lesson{"c": {}, "a": {}, "b": {}},
+			[]string{"a", "b", "c"},
+		},
+		{
+			"by order, unordered last",
+			map[string]*Lesson{"a": {}, "b": {Order: 2}, "c": {Order: 1}, "d": {Order: 2}},
+			[]string{"c", "b", "d", "a"},
+		},
+		{
+			"requirements first",
+			map[string]*Lesson{
+				"basics":  {Order: 1},
+				"methods": {Order: 2, Requires: []string{"types"}},
+				"types":   {Order: 3, Requires: []string{"basics"}},
+			},
+			[]string{"basics", "types", "methods"},
+		},
+		{
+			// Once a lesson is read, the order decides among the
+			// lessons it made ready and those that already were.
+			"ready lessons by order",
+			map[string]*Lesson{
+				"a": {Order: 1},
+				"b": {Order: 4},
+				"c": {Order: 2, Requires: []string{"a"}},
+				"d": {Order: 3, Requires: []string{"a", "c"}},
+			},
+			[]string{"a", "c", "d", "b"},
+		},
+	} {
+		nodes, err := lessonGraph(tt.lessons)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var got []string
+		for _, n := range nodes {
+			got = append(got, n.Name)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLessonGraphNodes(t *testing.T) {
+	nodes, err := lessonGraph(map[string]*Lesson{
+		"basics": {Title: "Basics", Order: 1, Level: "beginner"},
+		"flow":   {Title: "Flow", Order: 2, Requires: []string{"basics"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*lessonNode{
+		{Name: "basics", Title: "Basics", Level: "beginner", Next: "flow"},
+		{Name: "flow", Title: "Flow", Requires: []string{"basics"}, Prev: "basics"},
+	}
+	if !reflect.DeepEqual(nodes, want) {
+		t.Errorf("got %+v, %+v\nwant %+v, %+v", nodes[0], nodes[1], want[0], want[1])
+	}
+}
+
+func TestLessonGraphErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		lessons map[string]*Lesson
+		err     string
+	}{
+		{
+			"unknown requirement",
+			map[string]*Lesson{"a": {Requires: []string{"missing"}}},
+			`lesson "a" requires unknown lesson "missing"`,
+		},
+		{
+			"self",
+			map[string]*Lesson{"a": {Requires: []string{"a"}}},
+			"lesson prerequisites form a cycle: a -> a",
+		},
+		{
+			"cycle",
+			map[string]*Lesson{
+				"a": {Requires: []string{"b"}},
+				"b": {Requires: []string{"c"}},
+				"c": {Requires: []string{"a"}},
+			},
+			"lesson prerequisites form a cycle: a -> b -> c -> a",
+		},
+		{
+			// d is stuck behind the cycle but isn't part of it.
+			"lesson behind a cycle",
+			map[string]*Lesson{
+				"a": {},
+				"b": {Requires: []string{"a", "c"}},
+				"c": {Requires: []string{"b"}},
+				"d": {Requires: []string{"c"}},
+			},
+			"lesson prerequisites form a cycle: b -> c -> b",
+		},
+	} {
+		_, err := lessonGraph(tt.lessons)
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("%s: got error %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestFindCycle(t *testing.T) {
+	lessons := map[string]*Lesson{
+		"x": {Requires: []string{"y"}},
+		"y": {Requires: []string{"done", "z"}},
+		"z": {Requires: []string{"y"}},
+	}
+	// The walk starts at x, which is stuck behind the cycle, and
+	// skips the requirement that is already done.
+	pending := map[string]int{"x": 1, "y": 1, "z": 1}
+	if got, want := findCycle(lessons, pending), "y -> z -> y"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestHideDrafts(t *testing.T) {
+	lessons := map[string]*Lesson{
+		"basics":   {Order: 1},
+		"generics": {Order: 2, Draft: true, Requires: []string{"basics"}},
+		"methods":  {Order: 3, Requires: []string{"basics", "generics"}},
+		"extra":    {Requires: []string{"generics"}},
+	}
+	hideDrafts(lessons)
+	if _, ok := lessons["generics"]; ok {
+		t.Error("draft lesson not hidden")
+	}
+	if got := lessons["methods"].Requires; !reflect.DeepEqual(got, []string{"basics"}) {
+		t.Errorf("methods requires %q, want only basics", got)
+	}
+	if got := lessons["extra"].Requires; len(got) != 0 {
+		t.Errorf("extra requires %q, want nothing", got)
+	}
+	if _, err := lessonGraph(lessons); err != nil {
+		t.Errorf("lesson graph without the drafts: %v", err)
+	}
+}
@@ -1,19 +1,22 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"go/build"
+	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"runtime"
 	"strings"
-
-	"github.com/Go-zh/tools/playground/socket"
+	"syscall"
+	"time"
 )
 
 // 定义基本常量
@@ -38,6 +41,14 @@ WARNING!  WARNING!  WARNING!
 var (
 	httpListen  = flag.String("http", "127.0.0.1:3999", "ip and port")
 	openBrowser = flag.Bool("open", true, "open the default browser")
+	accessLog   = flag.Bool("access-log", false, "log every HTTP request")
+	sourceLang  = flag.String("lang", "zh", "language of the lessons directly in the content directory")
+	showDrafts  = flag.Bool("drafts", false, "include lessons marked Draft")
+
+	tlsCert         = flag.String("tls-cert", "", "TLS certificate file; serve HTTPS together with -tls-key")
+	tlsKey          = flag.String("tls-key", "", "TLS private key file")
+	tlsSelfSigned   = flag.Bool("tls-self-signed", false, "serve HTTPS with a generated self-signed certificate (local use only)")
+	shutdownTimeout = flag.Duration("shutdown-timeout", 10*time.Second, "how long to wait for running programs on shutdown")
 )
 
 var (
@@ -45,6 +56,9 @@ var (
 	gopath = os.Getenv("GOPATH")
 
 	httpAddr string
+
+	// useTLS reports whether the tour is served over HTTPS.
+	useTLS bool
 )
 
 // isRoot 检测路径path是否为根目录，依据标准为是否包含欢迎页
@@ -83,6 +97,24 @@ func main() {
 		log.Fatalf("Couldn't find tour files: %v", err)
 	}
 
+	switch flag.Arg(0) {
+	case "":
+	case "cli":
+		// 终端模式
+		if err := runCLI(root, flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "coverage":
+		// 输出各语言的翻译覆盖率
+		if err := reportCoverage(os.Stdout, root); err != nil {
+			log.Fatal(err)
+		}
+		return
+	default:
+		log.Fatalf("unknown command %q", flag.Arg(0))
+	}
+
 	log.Println("Serving content from ", root)
 
 	// 处理主机和端口
@@ -99,18 +131,33 @@ func main() {
 	// 设置http地址
 	httpAddr = host + ":" + port
 
+	tlsConf, err := tlsConfig(*tlsCert, *tlsKey, *tlsSelfSigned, host)
+	if err != nil {
+		log.Fatal(err)
+	}
+	useTLS = tlsConf != nil
+
+	// 每次运行的编译目录都放在这里，退出时统一清理
+	buildDir, err = ioutil.TempDir("", "gotour-")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(buildDir)
+
 	// 初始化
 	if err := initTour(root, "SocketTransport"); err != nil {
 		log.Fatal(err)
 	}
+	setReady(true)
 	// 解析url根目录
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		if err := renderUI(w); err != nil {
+		if err := renderUI(requestLang(w, r), w); err != nil {
 			log.Println(err)
 		}
 	})
 
 	http.HandleFunc("/lesson/", lessonHandler)
+	http.HandleFunc("/lesson-graph", graphHandler)
 
 	// 监听静态文件
 	static := http.FileServer(http.Dir(root))
@@ -120,28 +167,97 @@ func main() {
 	http.Handle("/favicon.ico", http.FileServer(http.Dir(imgDir)))
 
 	//监听socket
-	origin := &url.URL{Scheme: "http", Host: httpAddr}
-	http.Handle(SocketPath, socket.NewHandler(origin))
+	origin := &url.URL{Scheme: scheme(), Host: httpAddr}
+	ws := newSocketHandler(origin)
+	http.HandleFunc(SocketPath, func(w http.ResponseWriter, r *http.Request) {
+		// The socket outlives the server's read and write timeouts.
+		rc := http.NewResponseController(w)
+		rc.SetReadDeadline(time.Time{})
+		rc.SetWriteDeadline(time.Time{})
+		ws.ServeHTTP(w, r)
+	})
+
+	srv := &http.Server{
+		Addr:              httpAddr,
+		Handler:           instrument(http.DefaultServeMux, *accessLog),
+		TLSConfig:         tlsConf,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      60 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+	}
 
 	// 启动浏览器
 	go func() {
-		url := "http://" + httpAddr
+		url := scheme() + "://" + httpAddr
 		if waitServer(url) && *openBrowser && startBrowser(url) {
 			log.Printf("A browser window should open. If not, please visit %s", url)
 		} else {
 			log.Printf("Please open your web browser and visit %s", url)
 		}
 	}()
+
 	// 监听服务
-	log.Fatal(http.ListenAndServe(httpAddr, nil))
+	errc := make(chan error, 1)
+	go func() {
+		if useTLS {
+			errc <- srv.ListenAndServeTLS("", "")
+		} else {
+			errc <- srv.ListenAndServe()
+		}
+	}()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	select {
+	case err := <-errc:
+		os.RemoveAll(buildDir)
+		log.Fatal(err)
+	case s := <-sig:
+		log.Printf("Received %v, shutting down", s)
+	}
+	signal.Stop(sig)
+	shutdown(srv, *shutdownTimeout)
 }
 
-func init() {
-	socket.Environ = environ
+// shutdown stops accepting connections and waits up to timeout for the
+// programs that are still building or running before killing them.
+func shutdown(srv *http.Server, timeout time.Duration) {
+	setReady(false)
+	stopRuns()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("shutdown: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		runs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Println("shutdown: killing programs that are still running")
+		killRuns()
+		<-done
+	}
+}
+
+// scheme returns the URL scheme the tour is served on.
+func scheme() string {
+	if useTLS {
+		return "https"
+	}
+	return "http"
 }
 
 // environ returns the original execution environment with GOPATH
-// replaced (or added) with the value of the global var gopath.
+// replaced (or added) with the value of the global var gopath. Unless
+// GO111MODULE is set, module mode is left to the go command's auto mode,
+// so that snippets, built outside any module, find the tour's packages
+// on GOPATH.
 func environ() (env []string) {
 	for _, v := range os.Environ() {
 		if !strings.HasPrefix(v, "GOPATH=") {
@@ -149,13 +265,16 @@ func environ() (env []string) {
 		}
 	}
 	env = append(env, "GOPATH="+gopath)
+	if os.Getenv("GO111MODULE") == "" {
+		env = append(env, "GO111MODULE=auto")
+	}
 	return
 }
 
 // lessonHandler handler the HTTP requests for lessons.
 func lessonHandler(w http.ResponseWriter, r *http.Request) {
 	lesson := strings.TrimPrefix(r.URL.Path, "/lesson/")
-	if err := writeLesson(lesson, w); err != nil {
+	if err := writeLesson(lesson, requestLang(w, r), w); err != nil {
 		if err == lessonNotFound {
 			http.NotFound(w, r)
 		} else {
@@ -163,3 +282,11 @@ func lessonHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 }
+
+// graphHandler serves the lessons in reading order with their prerequisites.
+func graphHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := writeGraph(requestLang(w, r), w); err != nil {
+		log.Println(err)
+	}
+}
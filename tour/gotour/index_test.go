@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// resetRuns undoes shutdown's effect on the run state.
+func resetRuns() {
+	draining = false
+	runCtx, killRuns = context.WithCancel(context.Background())
+}
+
+func TestShutdownWaitsForRuns(t *testing.T) {
+	defer resetRuns()
+	if !startRun() {
+		t.Fatal("startRun: got false")
+	}
+	done := make(chan struct{})
+	go func() {
+		shutdown(&http.Server{}, time.Minute)
+		close(done)
+	}()
+
+	// Once shutdown has begun, new runs are refused.
+	for deadline := time.Now().Add(5 * time.Second); startRun(); {
+		runs.Done()
+		if time.Now().After(deadline) {
+			t.Fatal("runs still accepted after shutdown")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if atomic.LoadInt32(&ready) != 0 {
+		t.Error("still ready after shutdown")
+	}
+	select {
+	case <-done:
+		t.Fatal("shutdown returned while a program was running")
+	case <-time.After(50 * time.Millisecond):
+	}
+	runs.Done()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("shutdown didn't return after the program ended")
+	}
+	if runCtx.Err() != nil {
+		t.Error("runs killed though they ended in time")
+	}
+}
+
+func TestShutdownKillsRuns(t *testing.T) {
+	defer resetRuns()
+	if !startRun() {
+		t.Fatal("startRun: got false")
+	}
+	// A run that ends only when killed.
+	go func() {
+		<-runCtx.Done()
+		runs.Done()
+	}()
+	done := make(chan struct{})
+	go func() {
+		shutdown(&http.Server{}, 10*time.Millisecond)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("shutdown didn't kill the running program")
+	}
+}
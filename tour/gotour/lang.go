@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// langCookie remembers the language chosen with the lang parameter.
+const langCookie = "tour-lang"
+
+// requestLang returns the language to serve r in. An explicit lang
+// parameter wins and is remembered in a cookie, then comes the cookie
+// itself, then the Accept-Language header and finally the source language.
+func requestLang(w http.ResponseWriter, r *http.Request) string {
+	w.Header().Add("Vary", "Accept-Language, Cookie")
+	if lang := matchLang(r.FormValue("lang")); lang != "" {
+		http.SetCookie(w, &http.Cookie{
+			Name:   langCookie,
+			Value:  lang,
+			Path:   "/",
+			MaxAge: 365 * 24 * 60 * 60,
+		})
+		return lang
+	}
+	if c, err := r.Cookie(langCookie); err == nil {
+		if lang := matchLang(c.Value); lang != "" {
+			return lang
+		}
+	}
+	for _, tag := range acceptLanguages(r.Header.Get("Accept-Language")) {
+		if lang := matchLang(tag); lang != "" {
+			return lang
+		}
+	}
+	return *sourceLang
+}
+
+// matchLang returns the served language best matching tag, trying the
+// full tag first and then its primary subtag ("zh" for "zh-CN").
+// It returns "" if there is no match.
+func matchLang(tag string) string {
+	tag = strings.ToLower(strings.Replace(strings.TrimSpace(tag), "_", "-", -1))
+	if tag == "" {
+		return ""
+	}
+	for lang := range Lessons {
+		if strings.ToLower(lang) == tag {
+			return lang
+		}
+	}
+	if i := strings.Index(tag, "-"); i > 0 {
+		return matchLang(tag[:i])
+	}
+	return ""
+}
+
+// acceptLanguages returns the language tags of an Accept-Language header
+// ordered by decreasing quality.
+func acceptLanguages(header string) []string {
+	type tagQ struct {
+		tag string
+		q   float64
+	}
+	var tags []tagQ
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		for _, p := range fields[1:] {
+			p = strings.TrimSpace(p)
+			if strings.HasPrefix(p, "q=") {
+				if v, err := strconv.ParseFloat(p[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			tags = append(tags, tagQ{tag, q})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+	r := make([]string, len(tags))
+	for i, t := range tags {
+		r[i] = t.tag
+	}
+	return r
+}
+
+// uiTranslation returns the UI translation table for lang, read from
+// content/<lang>/translation.json. It returns "" when the language has no
+// such file, in which case the table built into the scripts is used.
+func uiTranslation(root, lang string) (string, error) {
+	b, err := ioutil.ReadFile(filepath.Join(root, "content", lang, "translation.json"))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if !json.Valid(b) {
+		return "", fmt.Errorf("%s/translation.json: invalid JSON", lang)
+	}
+	return string(b), nil
+}
+
+// coverage describes how much of a lesson is translated.
+type coverage struct {
+	Lang, Lesson string
+	Pages        int // pages in the source lesson
+	PagesDone    int // pages whose translation differs from the source
+	Snippets     int // code files in the source lesson
+	SnippetsDone int // code files whose translation differs from the source
+}
+
+// translationCoverage compares every translation with the source lessons.
+func translationCoverage(tours map[string]map[string]*Lesson) []coverage {
+	src := tours[*sourceLang]
+	var langs, names []string
+	for lang := range tours {
+		if lang != *sourceLang {
+			langs = append(langs, lang)
+		}
+	}
+	for name := range src {
+		names = append(names, name)
+	}
+	sort.Strings(langs)
+	sort.Strings(names)
+
+	var r []coverage
+	for _, lang := range langs {
+		for _, name := range names {
+			s, t := src[name], tours[lang][name]
+			c := coverage{Lang: lang, Lesson: name, Pages: len(s.Pages)}
+			for i, p := range s.Pages {
+				c.Snippets += len(p.Files)
+				if t == nil || i >= len(t.Pages) {
+					continue
+				}
+				tp := t.Pages[i]
+				if tp.Title != p.Title || tp.Content != p.Content {
+					c.PagesDone++
+				}
+				for j, f := range p.Files {
+					if j < len(tp.Files) && tp.Files[j].Hash != f.Hash {
+						c.SnippetsDone++
+					}
+				}
+			}
+			r = append(r, c)
+		}
+	}
+	return r
+}
+
+// reportCoverage parses the tour under root and writes a translation
+// coverage table to w.
+func reportCoverage(w io.Writer, root string) error {
+	tours, err := parseTour(root)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "LANG\tLESSON\tPAGES\tSNIPPETS\n")
+	percent := func(n, total int) string {
+		if total == 0 {
+			return "-"
+		}
+		return fmt.Sprintf("%d/%d (%d%%)", n, total, n*100/total)
+	}
+	var total coverage
+	for i, c := range translationCoverage(tours) {
+		if i > 0 && c.Lang != total.Lang {
+			fmt.Fprintf(tw, "%s\tTOTAL\t%s\t%s\n", total.Lang,
+				percent(total.PagesDone, total.Pages), percent(total.SnippetsDone, total.Snippets))
+			total = coverage{}
+		}
+		total.Lang = c.Lang
+		total.Pages += c.Pages
+		total.PagesDone += c.PagesDone
+		total.Snippets += c.Snippets
+		total.SnippetsDone += c.SnippetsDone
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", c.Lang, c.Lesson,
+			percent(c.PagesDone, c.Pages), percent(c.SnippetsDone, c.Snippets))
+	}
+	if total.Lang != "" {
+		fmt.Fprintf(tw, "%s\tTOTAL\t%s\t%s\n", total.Lang,
+			percent(total.PagesDone, total.Pages), percent(total.SnippetsDone, total.Snippets))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// withLangs serves the given languages for the duration of a test.
+func withLangs(t *testing.T, langs ...string) {
+	old := Lessons
+	Lessons = make(map[string]map[string][]byte)
+	for _, lang := range langs {
+		Lessons[lang] = make(map[string][]byte)
+	}
+	t.Cleanup(func() { Lessons = old })
+}
+
+func TestMatchLang(t *testing.T) {
+	withLangs(t, "zh", "en", "pt-BR")
+	for _, tt := range []struct {
+		tag, want string
+	}{
+		{"zh", "zh"},
+		{"zh-CN", "zh"},
+		{"zh_TW", "zh"},
+		{"EN", "en"},
+		{"en-us", "en"},
+		{"pt-BR", "pt-BR"},
+		{"pt_br", "pt-BR"},
+		{"pt", ""},
+		{"fr", ""},
+		{" en ", "en"},
+		{"", ""},
+	} {
+		if got := matchLang(tt.tag); got != tt.want {
+			t.Errorf("matchLang(%q) = %q, want %q", tt.tag, got, tt.want)
+		}
+	}
+}
+
+func TestAcceptLanguages(t *testing.T) {
+	for _, tt := range []struct {
+		header string
+		want   []string
+	}{
+		{"", []string{}},
+		{"en", []string{"en"}},
+		{"fr-CH, fr;q=0.9, en;q=0.8, de;q=0.7, *;q=0.5", []string{"fr-CH", "fr", "en", "de"}},
+		{"en;q=0.5, zh-CN", []string{"zh-CN", "en"}},
+		{"de;q=0.5, en;q=0.5", []string{"de", "en"}},
+		{"en;q=0, zh", []string{"zh"}},
+		{"en;q=bad, zh;q=0.5", []string{"en", "zh"}},
+		{" , *", []string{}},
+	} {
+		if got := acceptLanguages(tt.header); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("acceptLanguages(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestRequestLang(t *testing.T) {
+	withLangs(t, "zh", "en")
+	for _, tt := range []struct {
+		name   string
+		query  string
+		cookie string
+		accept string
+		want   string
+		set    bool // whether the cookie is set
+	}{
+		{"default", "", "", "", "zh", false},
+		{"accept", "", "", "fr, en-GB;q=0.8", "en", false},
+		{"no match", "", "", "fr, de", "zh", false},
+		{"cookie", "", "en", "zh", "en", false},
+		{"bad cookie", "", "fr", "en", "en", false},
+		{"parameter", "?lang=en", "zh", "zh", "en", true},
+		{"bad parameter", "?lang=fr", "en", "zh", "en", false},
+	} {
+		r := httptest.NewRequest("GET", "/"+tt.query, nil)
+		if tt.cookie != "" {
+			r.AddCookie(&http.Cookie{Name: langCookie, Value: tt.cookie})
+		}
+		if tt.accept != "" {
+			r.Header.Set("Accept-Language", tt.accept)
+		}
+		w := httptest.NewRecorder()
+		if got := requestLang(w, r); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+		set := w.Header().Get("Set-Cookie")
+		if (set != "") != tt.set || tt.set && !strings.HasPrefix(set, langCookie+"="+tt.want+";") {
+			t.Errorf("%s: got Set-Cookie %q", tt.name, set)
+		}
+		if vary := w.Header().Get("Vary"); vary != "Accept-Language, Cookie" {
+			t.Errorf("%s: got Vary %q", tt.name, vary)
+		}
+	}
+}
+
+// page returns a page with the given title and code file hashes.
+func page(title string, hashes ...string) Page {
+	p := Page{Title: title, Content: "<p>" + title + "</p>"}
+	for _, h := range hashes {
+		p.Files = append(p.Files, File{Name: "prog.go", Hash: h})
+	}
+	return p
+}
+
+func TestTranslationCoverage(t *testing.T) {
+	src := map[string]*Lesson{
+		"basics": {Pages: []Page{page("包", "a"), page("导入", "b", "c"), page("函数")}},
+		"flow":   {Pages: []Page{page("for", "d")}},
+	}
+	tours := map[string]map[string]*Lesson{
+		*sourceLang: src,
+		"en": {
+			// Two pages translated, one with a single snippet changed.
+			"basics": {Pages: []Page{page("Packages", "a2"), page("Imports", "b2", "c")}},
+		},
+		"fr": {
+			// Identical to the source: nothing translated.
+			"basics": src["basics"],
+			"flow":   src["flow"],
+		},
+	}
+	want := []coverage{
+		{Lang: "en", Lesson: "basics", Pages: 3, PagesDone: 2, Snippets: 3, SnippetsDone: 2},
+		{Lang: "en", Lesson: "flow", Pages: 1, PagesDone: 0, Snippets: 1, SnippetsDone: 0},
+		{Lang: "fr", Lesson: "basics", Pages: 3, PagesDone: 0, Snippets: 3, SnippetsDone: 0},
+		{Lang: "fr", Lesson: "flow", Pages: 1, PagesDone: 0, Snippets: 1, SnippetsDone: 0},
+	}
+	if got := translationCoverage(tours); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestMergeLessons(t *testing.T) {
+	src := map[string]*Lesson{
+		"basics": {Title: "基础", Pages: []Page{page("包"), page("导入"), page("函数")}},
+		"flow":   {Title: "流程控制", Pages: []Page{page("for")}},
+	}
+	for _, tt := range []struct {
+		name string
+		tr   map[string]*Lesson
+		want map[string][]string // page titles by lesson
+	}{
+		{
+			"untranslated",
+			nil,
+			map[string][]string{"basics": {"包", "导入", "函数"}, "flow": {"for"}},
+		},
+		{
+			"partial lesson",
+			map[string]*Lesson{"basics": {Title: "Basics", Pages: []Page{page("Packages")}}},
+			map[string][]string{"basics": {"Packages", "导入", "函数"}, "flow": {"for"}},
+		},
+		{
+			"complete lesson",
+			map[string]*Lesson{"flow": {Title: "Flow", Pages: []Page{page("for loops")}}},
+			map[string][]string{"basics": {"包", "导入", "函数"}, "flow": {"for loops"}},
+		},
+		{
+			"translation only",
+			map[string]*Lesson{"extra": {Title: "Extra", Pages: []Page{page("More")}}},
+			map[string][]string{"basics": {"包", "导入", "函数"}, "flow": {"for"}, "extra": {"More"}},
+		},
+	} {
+		got := make(map[string][]string)
+		for name, l := range mergeLessons(tt.tr, src) {
+			for _, p := range l.Pages {
+				got[name] = append(got[name], p.Title)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if len(src["basics"].Pages) != 3 {
+		t.Error("mergeLessons modified the source lessons")
+	}
+}
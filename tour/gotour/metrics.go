@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+func init() {
+	http.HandleFunc("/healthz", healthzHandler)
+	http.HandleFunc("/readyz", readyzHandler)
+	http.HandleFunc("/metrics", metricsHandler)
+}
+
+// ready is set to 1 once the tour content is loaded and back to 0 when
+// the server starts shutting down.
+var ready int32
+
+func setReady(ok bool) {
+	var v int32
+	if ok {
+		v = 1
+	}
+	atomic.StoreInt32(&ready, v)
+}
+
+// healthzHandler reports that the process is alive.
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.WriteString(w, "ok\n")
+}
+
+// readyzHandler reports whether the tour is able to serve lessons.
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if atomic.LoadInt32(&ready) == 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		io.WriteString(w, "not ready\n")
+		return
+	}
+	io.WriteString(w, "ok\n")
+}
+
+// metricsHandler writes the collected metrics in the Prometheus text format.
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := stats.writeTo(w); err != nil {
+		log.Println(err)
+	}
+}
+
+// buildBuckets are the upper bounds, in seconds, of the build duration histogram.
+var buildBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// Failure kinds recorded for runs.
+const (
+	failBuild  = "build"  // the program didn't compile
+	failStart  = "start"  // the binary couldn't be executed
+	failExit   = "exit"   // the program exited with a non-zero status
+	failKilled = "killed" // the program was killed by the user or the server
+)
+
+// metrics holds the counters exported on /metrics.
+type metrics struct {
+	mu         sync.Mutex
+	requests   map[requestKey]uint64
+	runs       uint64
+	failures   map[string]uint64
+	builds     []uint64 // cumulative counts per buildBuckets entry
+	buildSum   float64
+	buildCount uint64
+	sockets    int64
+	cacheHits  uint64
+	cacheMiss  uint64
+}
+
+type requestKey struct {
+	route string
+	code  int
+}
+
+var stats = &metrics{
+	requests: make(map[requestKey]uint64),
+	failures: make(map[string]uint64),
+	builds:   make([]uint64, len(buildBuckets)),
+}
+
+func (m *metrics) request(route string, code int) {
+	m.mu.Lock()
+	m.requests[requestKey{route, code}]++
+	m.mu.Unlock()
+}
+
+func (m *metrics) run() {
+	m.mu.Lock()
+	m.runs++
+	m.mu.Unlock()
+}
+
+func (m *metrics) failure(kind string) {
+	m.mu.Lock()
+	m.failures[kind]++
+	m.mu.Unlock()
+}
+
+func (m *metrics) build(d time.Duration) {
+	s := d.Seconds()
+	m.mu.Lock()
+	for i, b := range buildBuckets {
+		if s <= b {
+			m.builds[i]++
+		}
+	}
+	m.buildSum += s
+	m.buildCount++
+	m.mu.Unlock()
+}
+
+func (m *metrics) socket(delta int64) {
+	m.mu.Lock()
+	m.sockets += delta
+	m.mu.Unlock()
+}
+
+func (m *metrics) cache(hit bool) {
+	m.mu.Lock()
+	if hit {
+		m.cacheHits++
+	} else {
+		m.cacheMiss++
+	}
+	m.mu.Unlock()
+}
+
+// writeTo writes m to w in the Prometheus text exposition format.
+func (m *metrics) writeTo(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b := bufio.NewWriter(w)
+	header := func(name, typ, help string) {
+		fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+
+	header("gotour_http_requests_total", "counter", "HTTP requests by route and status code.")
+	keys := make([]requestKey, 0, len(m.requests))
+	for k := range m.requests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+		return keys[i].code < keys[j].code
+	})
+	for _, k := range keys {
+		fmt.Fprintf(b, "gotour_http_requests_total{route=%s,code=\"%d\"} %d\n", strconv.Quote(k.route), k.code, m.requests[k])
+	}
+
+	header("gotour_runs_total", "counter", "Programs submitted for running.")
+	fmt.Fprintf(b, "gotour_runs_total %d\n", m.runs)
+
+	header("gotour_run_failures_total", "counter", "Failed runs by kind.")
+	for _, kind := range []string{failBuild, failStart, failExit, failKilled} {
+		fmt.Fprintf(b, "gotour_run_failures_total{kind=%q} %d\n", kind, m.failures[kind])
+	}
+
+	header("gotour_build_duration_seconds", "histogram", "Time spent compiling programs.")
+	for i, le := range buildBuckets {
+		fmt.Fprintf(b, "gotour_build_duration_seconds_bucket{le=\"%g\"} %d\n", le, m.builds[i])
+	}
+	fmt.Fprintf(b, "gotour_build_duration_seconds_bucket{le=\"+Inf\"} %d\n", m.buildCount)
+	fmt.Fprintf(b, "gotour_build_duration_seconds_sum %g\n", m.buildSum)
+	fmt.Fprintf(b, "gotour_build_duration_seconds_count %d\n", m.buildCount)
+
+	header("gotour_websocket_connections", "gauge", "Open websocket connections.")
+	fmt.Fprintf(b, "gotour_websocket_connections %d\n", m.sockets)
+
+	header("gotour_http_cache_requests_total", "counter", "Requests for cacheable assets by result.")
+	fmt.Fprintf(b, "gotour_http_cache_requests_total{result=\"hit\"} %d\n", m.cacheHits)
+	fmt.Fprintf(b, "gotour_http_cache_requests_total{result=\"miss\"} %d\n", m.cacheMiss)
+
+	header("gotour_http_cache_hit_ratio", "gauge", "Share of cacheable asset requests answered with 304 Not Modified.")
+	ratio := 0.0
+	if total := m.cacheHits + m.cacheMiss; total > 0 {
+		ratio = float64(m.cacheHits) / float64(total)
+	}
+	fmt.Fprintf(b, "gotour_http_cache_hit_ratio %g\n", ratio)
+
+	return b.Flush()
+}
+
+// cacheable reports whether responses for route may be revalidated by the
+// browser, and so count towards the cache hit rate.
+func cacheable(route string) bool {
+	switch route {
+	case "/script.js", "/static/", "/content/img/", "/favicon.ico":
+		return true
+	}
+	return false
+}
+
+// statusWriter records the status code and size of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+// Hijack lets the websocket handler take over the connection.
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("hijack not supported")
+	}
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// instrument wraps the handlers registered on mux, counting requests per
+// route and, if logging is set, writing one key=value access log line
+// per request.
+func instrument(mux *http.ServeMux, logging bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		_, route := mux.Handler(r)
+		if route == "" {
+			route = "unmatched"
+		}
+		sw := &statusWriter{ResponseWriter: w}
+		mux.ServeHTTP(sw, r)
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+
+		stats.request(route, sw.status)
+		if cacheable(route) && r.Method == "GET" {
+			stats.cache(sw.status == http.StatusNotModified)
+		}
+		if logging {
+			log.Printf("access method=%s path=%s route=%s status=%d bytes=%d duration=%s remote=%s agent=%s",
+				r.Method, strconv.Quote(r.URL.Path), strconv.Quote(route), sw.status, sw.size,
+				time.Since(start), r.RemoteAddr, strconv.Quote(strings.TrimSpace(r.UserAgent())))
+		}
+	})
+}
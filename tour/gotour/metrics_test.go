@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// freshStats replaces stats for the duration of a test.
+func freshStats(t *testing.T) {
+	old := stats
+	stats = &metrics{
+		requests: make(map[requestKey]uint64),
+		failures: make(map[string]uint64),
+		builds:   make([]uint64, len(buildBuckets)),
+	}
+	t.Cleanup(func() { stats = old })
+}
+
+func TestHealthHandlers(t *testing.T) {
+	defer setReady(false)
+	for _, tt := range []struct {
+		handler http.HandlerFunc
+		ready   bool
+		code    int
+		body    string
+	}{
+		{healthzHandler, false, http.StatusOK, "ok\n"},
+		{readyzHandler, false, http.StatusServiceUnavailable, "not ready\n"},
+		{readyzHandler, true, http.StatusOK, "ok\n"},
+	} {
+		setReady(tt.ready)
+		w := httptest.NewRecorder()
+		tt.handler(w, httptest.NewRequest("GET", "/", nil))
+		if w.Code != tt.code || w.Body.String() != tt.body {
+			t.Errorf("ready %v: got %d %q, want %d %q", tt.ready, w.Code, w.Body, tt.code, tt.body)
+		}
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	freshStats(t)
+	stats.request("/lesson/", 200)
+	stats.request("/lesson/", 404)
+	stats.request("/", 200)
+	stats.run()
+	stats.failure(failBuild)
+	stats.build(300 * time.Millisecond)
+	stats.socket(1)
+	stats.cache(true)
+	stats.cache(true)
+	stats.cache(false)
+	stats.cache(false)
+
+	w := httptest.NewRecorder()
+	metricsHandler(w, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("got Content-Type %q", ct)
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		"# TYPE gotour_http_requests_total counter\n" +
+			`gotour_http_requests_total{route="/",code="200"} 1` + "\n" +
+			`gotour_http_requests_total{route="/lesson/",code="200"} 1` + "\n" +
+			`gotour_http_requests_total{route="/lesson/",code="404"} 1` + "\n",
+		"gotour_runs_total 1\n",
+		`gotour_run_failures_total{kind="build"} 1` + "\n",
+		`gotour_run_failures_total{kind="killed"} 0` + "\n",
+		`gotour_build_duration_seconds_bucket{le="0.25"} 0` + "\n" +
+			`gotour_build_duration_seconds_bucket{le="0.5"} 1` + "\n",
+		`gotour_build_duration_seconds_bucket{le="+Inf"} 1` + "\n",
+		"gotour_build_duration_seconds_count 1\n",
+		"gotour_websocket_connections 1\n",
+		`gotour_http_cache_requests_total{result="hit"} 2` + "\n",
+		"gotour_http_cache_hit_ratio 0.5\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestInstrument(t *testing.T) {
+	freshStats(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/lesson/", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "lesson")
+	})
+	mux.HandleFunc("/static/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotModified)
+	})
+	h := instrument(mux, false)
+	for _, path := range []string{"/lesson/basics", "/lesson/flow", "/static/app.css", "/nowhere"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	for k, want := range map[requestKey]uint64{
+		{"/lesson/", 200}:  2,
+		{"/static/", 304}:  1,
+		{"unmatched", 404}: 1,
+	} {
+		if got := stats.requests[k]; got != want {
+			t.Errorf("requests %v: got %d, want %d", k, got, want)
+		}
+	}
+	if stats.cacheHits != 1 || stats.cacheMiss != 0 {
+		t.Errorf("got %d cache hits and %d misses, want 1 and 0", stats.cacheHits, stats.cacheMiss)
+	}
+}
+
+func TestStatusWriter(t *testing.T) {
+	for _, tt := range []struct {
+		write func(w http.ResponseWriter)
+		code  int
+		size  int
+	}{
+		{func(w http.ResponseWriter) { io.WriteString(w, "hello") }, 200, 5},
+		{func(w http.ResponseWriter) { w.WriteHeader(201); w.WriteHeader(500) }, 201, 0},
+		{func(w http.ResponseWriter) { w.WriteHeader(404); io.WriteString(w, "no") }, 404, 2},
+	} {
+		sw := &statusWriter{ResponseWriter: httptest.NewRecorder()}
+		tt.write(sw)
+		if sw.status != tt.code || sw.size != tt.size {
+			t.Errorf("got status %d and size %d, want %d and %d", sw.status, sw.size, tt.code, tt.size)
+		}
+	}
+
+	// A recorder can't be hijacked.
+	sw := &statusWriter{ResponseWriter: httptest.NewRecorder()}
+	if _, _, err := sw.Hijack(); err == nil {
+		t.Error("Hijack of a ResponseRecorder: got no error")
+	}
+}
+
+func TestStatusWriterPassthrough(t *testing.T) {
+	freshStats(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/socket", func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Errorf("Hijack: %v", err)
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n\r\nhijacked")
+		rw.Flush()
+	})
+	mux.HandleFunc("/flush", func(w http.ResponseWriter, r *http.Request) {
+		// The controller reaches the server's writer through Unwrap.
+		rc := http.NewResponseController(w)
+		if err := rc.SetReadDeadline(time.Time{}); err != nil {
+			t.Errorf("SetReadDeadline: %v", err)
+		}
+		io.WriteString(w, "flushed")
+		if err := rc.Flush(); err != nil {
+			t.Errorf("Flush: %v", err)
+		}
+	})
+	srv := httptest.NewServer(instrument(mux, false))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/flush")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(b) != "flushed" {
+		t.Errorf("/flush: got %q", b)
+	}
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "GET /socket HTTP/1.1\r\nHost: tour\r\n\r\n")
+	br := bufio.NewReader(conn)
+	status, _ := br.ReadString('\n')
+	if !strings.HasPrefix(status, "HTTP/1.1 101") {
+		t.Errorf("/socket: got status line %q", status)
+	}
+	// Wait for the handler to return before reading the counters.
+	io.ReadAll(br)
+	srv.Close()
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	if got := stats.requests[requestKey{"/socket", http.StatusSwitchingProtocols}]; got != 1 {
+		t.Errorf("got %d /socket requests with status 101, want 1", got)
+	}
+}
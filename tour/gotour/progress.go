@@ -0,0 +1,212 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/Tobecoder/go/tools/present"
+)
+
+func init() {
+	http.HandleFunc("/progress", progressHandler)
+}
+
+// progress is what the tour remembers between sessions. The terminal
+// tour keeps it in progress.json in the state directory. The server may
+// be shared by many readers, so it keeps the answers of each browser
+// apart, in readers/ID/progress.json with the ID issued in readerCookie.
+type progress struct {
+	Lesson  string
+	Page    int
+	Read    map[string]int    // furthest page read per lesson
+	Answers map[string]answer `json:",omitempty"` // latest answer per quiz, by "lang/lesson/quiz ID"
+}
+
+// answer is a reader's answer to a quiz.
+type answer struct {
+	Choice  string
+	Correct bool
+}
+
+// stateDir returns the directory of the tour's state files.
+func stateDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".gotour"), nil
+}
+
+// readerCookie holds the ID under which the server keeps the answers
+// given in a browser.
+const readerCookie = "tour-reader"
+
+// readerDir returns the directory, in the state directory dir, of the
+// reader of r, first issuing them an ID if they have none.
+func readerDir(w http.ResponseWriter, r *http.Request, dir string) (string, error) {
+	if c, err := r.Cookie(readerCookie); err == nil {
+		if b, err := hex.DecodeString(c.Value); err == nil && len(b) == 16 {
+			return filepath.Join(dir, "readers", c.Value), nil
+		}
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(b)
+	http.SetCookie(w, &http.Cookie{
+		Name:     readerCookie,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   365 * 24 * 60 * 60,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return filepath.Join(dir, "readers", id), nil
+}
+
+// loadProgress reads the progress saved in dir, if any.
+func loadProgress(dir string) (progress, error) {
+	p := progress{Read: make(map[string]int), Answers: make(map[string]answer)}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "progress.json"))
+	if os.IsNotExist(err) {
+		return p, nil
+	}
+	if err != nil {
+		return p, err
+	}
+	err = json.Unmarshal(b, &p)
+	if p.Answers == nil {
+		p.Answers = make(map[string]answer)
+	}
+	return p, err
+}
+
+// save writes the progress to dir.
+func (p *progress) save(dir string) error {
+	b, err := json.MarshalIndent(p, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, "progress.json"), b, 0644)
+}
+
+// answer records choice as the answer to quiz q of lesson in lang.
+func (p *progress) answer(lang, lesson string, q present.Quiz, choice string) (answer, error) {
+	if _, ok := q.Choice(choice); !ok {
+		return answer{}, fmt.Errorf("no choice %q", choice)
+	}
+	a := answer{Choice: choice, Correct: choice == q.Answer()}
+	p.Answers[lang+"/"+lesson+"/"+q.ID] = a
+	return a, nil
+}
+
+// langAnswers returns the answers given in lang, by "lesson/quiz ID".
+func (p *progress) langAnswers(lang string) map[string]answer {
+	r := make(map[string]answer)
+	for k, a := range p.Answers {
+		if strings.HasPrefix(k, lang+"/") {
+			r[k[len(lang)+1:]] = a
+		}
+	}
+	return r
+}
+
+var (
+	// quizzes holds the quizzes of the lessons by "lang/lesson/quiz ID",
+	// for checking the answers sent to the server.
+	quizzes = make(map[string]present.Quiz)
+
+	progressMu sync.Mutex // guards the progress files against concurrent requests
+)
+
+// findQuiz returns the quiz id of lesson as served in lang. Lessons not
+// translated into lang are served in the source language, so their
+// quizzes are looked up there.
+func findQuiz(lang, lesson, id string) (present.Quiz, bool) {
+	if q, ok := quizzes[lang+"/"+lesson+"/"+id]; ok {
+		return q, true
+	}
+	q, ok := quizzes[*sourceLang+"/"+lesson+"/"+id]
+	return q, ok
+}
+
+// findQuizzes returns the quizzes in e.
+func findQuizzes(e present.Elem) []present.Quiz {
+	switch e := e.(type) {
+	case present.Quiz:
+		return []present.Quiz{e}
+	case present.Section:
+		var r []present.Quiz
+		for _, s := range e.Elem {
+			r = append(r, findQuizzes(s)...)
+		}
+		return r
+	}
+	return nil
+}
+
+// progressHandler serves the quiz answers the reader saved in the
+// language of the request on GET, and on POST records the answer given by
+// the form values lesson, quiz and choice, replying with whether it was
+// right and the right choice.
+func progressHandler(w http.ResponseWriter, r *http.Request) {
+	dir, err := stateDir()
+	if err == nil {
+		dir, err = readerDir(w, r, dir)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	p, err := loadProgress(dir)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "cannot read progress", http.StatusInternalServerError)
+		return
+	}
+	lang := requestLang(w, r)
+	w.Header().Set("Content-Type", "application/json")
+	switch r.Method {
+	case "GET":
+		json.NewEncoder(w).Encode(p.langAnswers(lang))
+	case "POST":
+		lesson := r.FormValue("lesson")
+		q, ok := findQuiz(lang, lesson, r.FormValue("quiz"))
+		if !ok {
+			http.Error(w, "no such quiz", http.StatusNotFound)
+			return
+		}
+		a, err := p.answer(lang, lesson, q, r.FormValue("choice"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := p.save(dir); err != nil {
+			log.Println(err)
+			http.Error(w, "cannot save progress", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(struct {
+			Correct bool
+			Answer  string
+		}{a.Correct, q.Answer()})
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Tobecoder/go/tools/present"
+)
+
+const quizLesson = `Quizzes
+
+* 小测验
+
+.quiz zero-value
+	Question: 用 var 声明一个 string 类型的变量 s 而不赋初值，s 的值是什么？
+	a) nil
+	b) "" （空字符串）
+	c) 未定义，读取它会导致运行时错误
+	Answer: b
+	Explanation: 没有明确初始值的变量会被赋予零值，字符串的零值是空字符串。
+
+.quiz conversion
+	Question: i 是一个 int 类型的变量，下面哪条语句能通过编译？
+	a) var f float64 = i
+	b) f := float64(i)
+	c) var u uint = i
+	Answer: b
+	Explanation: Go 在不同类型的项之间赋值时需要显式转换。
+`
+
+// testQuizzes returns the quizzes of quizLesson by ID.
+func testQuizzes(t *testing.T) map[string]present.Quiz {
+	doc, err := present.Parse(strings.NewReader(quizLesson), "quiz.article", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	qs := make(map[string]present.Quiz)
+	for _, s := range doc.Sections {
+		for _, q := range findQuizzes(s) {
+			qs[q.ID] = q
+		}
+	}
+	if len(qs) != 2 {
+		t.Fatalf("got %d quizzes, want 2", len(qs))
+	}
+	return qs
+}
+
+func TestProgressSaveLoad(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "state")
+	p, err := loadProgress(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Read == nil || p.Answers == nil || p.Lesson != "" {
+		t.Fatalf("got fresh progress %+v", p)
+	}
+	p.Lesson, p.Page = "basics", 3
+	p.Read["basics"] = 4
+	p.Answers["zh/basics/zero-value"] = answer{Choice: "b", Correct: true}
+	if err := p.save(dir); err != nil {
+		t.Fatal(err)
+	}
+	got, err := loadProgress(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, p) {
+		t.Errorf("loaded %+v, want %+v", got, p)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "progress.json"), []byte(`{"Lesson": "basics"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if p, err := loadProgress(dir); err != nil || p.Answers == nil {
+		t.Errorf("progress without answers: %+v, %v", p, err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "progress.json"), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadProgress(dir); err == nil {
+		t.Error("no error for a corrupt progress file")
+	}
+}
+
+func TestProgressAnswer(t *testing.T) {
+	q := testQuizzes(t)["zero-value"]
+	p := progress{Answers: make(map[string]answer)}
+	for _, tt := range []struct {
+		lang, choice string
+		correct      bool
+	}{
+		{"zh", "b", true},
+		{"en", "a", false},
+	} {
+		a, err := p.answer(tt.lang, "basics", q, tt.choice)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := answer{Choice: tt.choice, Correct: tt.correct}
+		if a != want {
+			t.Errorf("%s: answer %s gave %+v, want %+v", tt.lang, tt.choice, a, want)
+		}
+		if got := p.Answers[tt.lang+"/basics/zero-value"]; got != want {
+			t.Errorf("%s: recorded %+v, want %+v", tt.lang, got, want)
+		}
+	}
+	if _, err := p.answer("zh", "basics", q, "d"); err == nil {
+		t.Error("no error for a choice the quiz doesn't have")
+	}
+	if len(p.Answers) != 2 {
+		t.Errorf("got answers %v", p.Answers)
+	}
+
+	want := map[string]answer{"basics/zero-value": {Choice: "a"}}
+	if got := p.langAnswers("en"); !reflect.DeepEqual(got, want) {
+		t.Errorf("en answers %v, want %v", got, want)
+	}
+	if got := p.langAnswers("fr"); len(got) != 0 {
+		t.Errorf("fr answers %v, want none", got)
+	}
+}
+
+// withQuizzes serves the quizzes of quizLesson as lesson basics, with
+// only the conversion quiz translated into English, and keeps the
+// progress in a temporary home directory, which it returns.
+func withQuizzes(t *testing.T) string {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	withLangs(t, "zh", "en")
+	old := quizzes
+	t.Cleanup(func() { quizzes = old })
+	qs := testQuizzes(t)
+	quizzes = map[string]present.Quiz{
+		"zh/basics/zero-value": qs["zero-value"],
+		"zh/basics/conversion": qs["conversion"],
+		"en/basics/conversion": qs["conversion"],
+	}
+	return home
+}
+
+// doProgress sends a request to progressHandler from the reader with
+// the ID reader, or from a new reader if it is empty.
+func doProgress(method, lang, reader string, form url.Values) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/progress", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.AddCookie(&http.Cookie{Name: langCookie, Value: lang})
+	if reader != "" {
+		r.AddCookie(&http.Cookie{Name: readerCookie, Value: reader})
+	}
+	w := httptest.NewRecorder()
+	progressHandler(w, r)
+	return w
+}
+
+func TestProgressHandler(t *testing.T) {
+	home := withQuizzes(t)
+	const reader = "00112233445566778899aabbccddeeff"
+	do := func(method, lang string, form url.Values) *httptest.ResponseRecorder {
+		return doProgress(method, lang, reader, form)
+	}
+	post := func(lang, lesson, quiz, choice string) *httptest.ResponseRecorder {
+		return do("POST", lang, url.Values{"lesson": {lesson}, "quiz": {quiz}, "choice": {choice}})
+	}
+
+	for _, tt := range []struct {
+		lang, lesson, quiz, choice string
+		code                       int
+		correct                    bool
+	}{
+		{"zh", "basics", "zero-value", "b", http.StatusOK, true},
+		{"en", "basics", "conversion", "a", http.StatusOK, false},
+		{"en", "basics", "zero-value", "c", http.StatusOK, false}, // not translated: the source quiz
+		{"zh", "basics", "missing", "a", http.StatusNotFound, false},
+		{"zh", "flowcontrol", "zero-value", "a", http.StatusNotFound, false},
+		{"zh", "basics", "conversion", "d", http.StatusBadRequest, false},
+	} {
+		w := post(tt.lang, tt.lesson, tt.quiz, tt.choice)
+		if w.Code != tt.code {
+			t.Errorf("%s %s/%s %s: got status %d, want %d", tt.lang, tt.lesson, tt.quiz, tt.choice, w.Code, tt.code)
+			continue
+		}
+		if tt.code != http.StatusOK {
+			continue
+		}
+		var resp struct {
+			Correct bool
+			Answer  string
+		}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Correct != tt.correct || resp.Answer != "b" {
+			t.Errorf("%s %s/%s %s: got %+v", tt.lang, tt.lesson, tt.quiz, tt.choice, resp)
+		}
+	}
+
+	for lang, want := range map[string]map[string]answer{
+		"zh": {"basics/zero-value": {Choice: "b", Correct: true}},
+		"en": {"basics/conversion": {Choice: "a"}, "basics/zero-value": {Choice: "c"}},
+	} {
+		w := do("GET", lang, nil)
+		var got map[string]answer
+		if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("GET in %s: got %v, want %v", lang, got, want)
+		}
+	}
+
+	p, err := loadProgress(filepath.Join(home, ".gotour", "readers", reader))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Answers) != 3 || !p.Answers["zh/basics/zero-value"].Correct {
+		t.Errorf("saved answers %v", p.Answers)
+	}
+
+	w := do("PUT", "zh", nil)
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET, POST" {
+		t.Errorf("PUT: got status %d, Allow %q", w.Code, w.Header().Get("Allow"))
+	}
+}
+
+func TestProgressReaders(t *testing.T) {
+	home := withQuizzes(t)
+	form := func(choice string) url.Values {
+		return url.Values{"lesson": {"basics"}, "quiz": {"zero-value"}, "choice": {choice}}
+	}
+	// issued returns the reader ID set by the response w, if any.
+	issued := func(w *httptest.ResponseRecorder) string {
+		for _, c := range w.Result().Cookies() {
+			if c.Name == readerCookie {
+				return c.Value
+			}
+		}
+		return ""
+	}
+
+	alice := issued(doProgress("POST", "zh", "", form("b")))
+	bob := issued(doProgress("POST", "zh", "../../etc", form("a")))
+	if alice == "" || bob == "" || alice == bob {
+		t.Fatalf("got reader IDs %q and %q, want two new ones", alice, bob)
+	}
+	if w := doProgress("POST", "zh", alice, form("c")); w.Code != http.StatusOK || issued(w) != "" {
+		t.Errorf("known reader: got status %d and new ID %q", w.Code, issued(w))
+	}
+
+	for reader, want := range map[string]string{alice: "c", bob: "a"} {
+		var got map[string]answer
+		if err := json.NewDecoder(doProgress("GET", "zh", reader, nil).Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 || got["basics/zero-value"].Choice != want {
+			t.Errorf("reader %s: got answers %v, want only %s", reader, got, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(home, ".gotour", "progress.json")); !os.IsNotExist(err) {
+		t.Errorf("server answers written to the terminal tour's progress: %v", err)
+	}
+}
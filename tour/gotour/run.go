@@ -0,0 +1,464 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Go-zh/tools/playground/socket"
+	"golang.org/x/net/websocket"
+)
+
+// The tour speaks the wire protocol of the playground socket package but
+// starts the programs itself: the package has no hooks to time builds,
+// count failures, or stop runs and remove their build directories when
+// the tour shuts down.
+
+const (
+	// The maximum number of messages to send per session (avoid flooding).
+	msgLimit = 1000
+
+	// Batch messages sent in this interval and send as a single message.
+	msgDelay = 10 * time.Millisecond
+)
+
+var (
+	// buildDir is the directory under which every run gets its own
+	// temporary build directory. Empty means the system default.
+	buildDir string
+
+	// runs tracks the processes that are building or running.
+	runs sync.WaitGroup
+
+	runsMu   sync.Mutex // guards draining and the calls to runs.Add
+	draining bool       // set by stopRuns; no new runs are started
+
+	// runCtx is cancelled by killRuns to stop every build and run.
+	runCtx, killRuns = context.WithCancel(context.Background())
+)
+
+type (
+	Message = socket.Message
+	Options = socket.Options
+)
+
+// newSocketHandler returns a websocket server which checks the origin of requests.
+func newSocketHandler(origin *url.URL) websocket.Server {
+	return websocket.Server{
+		Config:    websocket.Config{Origin: origin},
+		Handshake: handshake,
+		Handler:   websocket.Handler(socketHandler),
+	}
+}
+
+// handshake checks the origin of a request during the websocket handshake.
+func handshake(c *websocket.Config, req *http.Request) error {
+	o, err := websocket.Origin(c, req)
+	if err != nil {
+		log.Println("bad websocket origin:", err)
+		return websocket.ErrBadWebSocketOrigin
+	}
+	_, port, err := net.SplitHostPort(c.Origin.Host)
+	if err != nil {
+		log.Println("bad websocket origin:", err)
+		return websocket.ErrBadWebSocketOrigin
+	}
+	ok := c.Origin.Scheme == o.Scheme && (c.Origin.Host == o.Host || c.Origin.Host == net.JoinHostPort(o.Host, port))
+	if !ok {
+		log.Println("bad websocket origin:", o)
+		return websocket.ErrBadWebSocketOrigin
+	}
+	log.Println("accepting connection from:", req.RemoteAddr)
+	return nil
+}
+
+// socketHandler handles the websocket connection for a given tour session.
+// It handles transcoding Messages to and from JSON format, and starting
+// and killing processes.
+func socketHandler(c *websocket.Conn) {
+	stats.socket(1)
+	defer stats.socket(-1)
+
+	in, out := make(chan *Message), make(chan *Message)
+	errc := make(chan error, 2)
+	quit := make(chan struct{})
+	defer close(quit)
+
+	// Decode messages from client and send to the in channel.
+	go func() {
+		dec := json.NewDecoder(c)
+		for {
+			var m Message
+			if err := dec.Decode(&m); err != nil {
+				errc <- err
+				return
+			}
+			select {
+			case in <- &m:
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	// Receive messages from the out channel and encode to the client.
+	// Once encoding fails the messages are dropped, so that the processes
+	// can still send their output and end, and Kill can wait for them.
+	go func() {
+		enc := json.NewEncoder(c)
+		for m := range out {
+			if err := enc.Encode(m); err != nil {
+				errc <- err
+				break
+			}
+		}
+		for range out {
+		}
+	}()
+	defer close(out)
+
+	// Start and kill processes and handle errors.
+	proc := make(map[string]*process)
+	for {
+		select {
+		case m := <-in:
+			switch m.Kind {
+			case "run":
+				log.Println("running snippet from:", c.Request().RemoteAddr)
+				proc[m.Id].Kill()
+				proc[m.Id] = startProcess(m.Id, m.Body, out, m.Options)
+			case "kill":
+				proc[m.Id].Kill()
+			}
+		case err := <-errc:
+			if err != io.EOF {
+				// A encode or decode has failed; bail.
+				log.Println(err)
+			}
+			// Shut down any running processes.
+			for _, p := range proc {
+				p.Kill()
+			}
+			return
+		}
+	}
+}
+
+// process represents a running process.
+type process struct {
+	out  chan<- *Message
+	done chan struct{} // closed when wait completes
+	run  *exec.Cmd
+	path string
+}
+
+// startProcess builds and runs the given program, sending its output
+// and end event as Messages on the provided channel.
+func startProcess(id, body string, dest chan<- *Message, opt *Options) *process {
+	var (
+		done = make(chan struct{})
+		out  = make(chan *Message)
+		p    = &process{out: out, done: done}
+	)
+	if !startRun() {
+		dest <- &Message{Id: id, Kind: "end", Body: "the tour is shutting down"}
+		return nil
+	}
+	stats.run()
+	go func() {
+		defer runs.Done()
+		defer close(done)
+		for m := range buffer(limiter(out, p)) {
+			m.Id = id
+			dest <- m
+		}
+	}()
+	if err := p.start(body, opt); err != nil {
+		p.end(err)
+		return p
+	}
+	go func() {
+		err := p.run.Wait()
+		if err != nil {
+			stats.failure(exitKind(err))
+		}
+		p.end(err)
+	}()
+	return p
+}
+
+// startRun adds a run to runs, unless stopRuns has been called, and
+// reports whether it did.
+func startRun() bool {
+	runsMu.Lock()
+	defer runsMu.Unlock()
+	if draining {
+		return false
+	}
+	runs.Add(1)
+	return true
+}
+
+// stopRuns makes startRun refuse new runs, so that runs.Wait can't race
+// with runs.Add.
+func stopRuns() {
+	runsMu.Lock()
+	draining = true
+	runsMu.Unlock()
+}
+
+// end sends an "end" message to the client, containing the process id and the
+// given error value. It also removes the binary, if present.
+func (p *process) end(err error) {
+	if p.path != "" {
+		defer os.RemoveAll(p.path)
+	}
+	m := &Message{Kind: "end"}
+	if err != nil {
+		m.Body = err.Error()
+	}
+	p.out <- m
+	close(p.out)
+}
+
+// A killer provides a mechanism to terminate a process.
+// The Kill method returns only once the process has exited.
+type killer interface {
+	Kill()
+}
+
+// limiter returns a channel that wraps the given channel.
+// It receives Messages from the given channel and sends them to the returned
+// channel until it passes msgLimit messages, at which point it will kill the
+// process and pass only the "end" message.
+// When the given channel is closed, or when the "end" message is received,
+// it closes the returned channel.
+func limiter(in <-chan *Message, p killer) <-chan *Message {
+	out := make(chan *Message)
+	go func() {
+		defer close(out)
+		n := 0
+		for m := range in {
+			switch {
+			case n < msgLimit || m.Kind == "end":
+				out <- m
+				if m.Kind == "end" {
+					return
+				}
+			case n == msgLimit:
+				// Kill in a goroutine as Kill will not return
+				// until the process' output has been
+				// processed, and we're doing that in this loop.
+				go p.Kill()
+			default:
+				continue // don't increment
+			}
+			n++
+		}
+	}()
+	return out
+}
+
+// buffer returns a channel that wraps the given channel. It receives messages
+// from the given channel and sends them to the returned channel.
+// Message bodies are gathered over the period msgDelay and coalesced into a
+// single Message before they are passed on. Messages of the same kind are
+// coalesced; when a message of a different kind is received, any buffered
+// messages are flushed. When the given channel is closed, buffer flushes the
+// remaining buffered messages and closes the returned channel.
+func buffer(in <-chan *Message) <-chan *Message {
+	out := make(chan *Message)
+	go func() {
+		defer close(out)
+		var (
+			tc    <-chan time.Time
+			buf   []byte
+			kind  string
+			flush = func() {
+				if len(buf) == 0 {
+					return
+				}
+				out <- &Message{Kind: kind, Body: safeString(buf)}
+				buf = buf[:0] // recycle buffer
+				kind = ""
+			}
+		)
+		for {
+			select {
+			case m, ok := <-in:
+				if !ok {
+					flush()
+					return
+				}
+				if m.Kind == "end" {
+					flush()
+					out <- m
+					return
+				}
+				if kind != m.Kind {
+					flush()
+					kind = m.Kind
+					if tc == nil {
+						tc = time.After(msgDelay)
+					}
+				}
+				buf = append(buf, m.Body...)
+			case <-tc:
+				flush()
+				tc = nil
+			}
+		}
+	}()
+	return out
+}
+
+// Kill stops the process if it is running and waits for it to exit and
+// for its output to be sent.
+func (p *process) Kill() {
+	if p == nil {
+		return
+	}
+	if p.run != nil {
+		p.run.Process.Kill()
+	}
+	<-p.done // block until process exits
+}
+
+// start builds and starts the given program, sending its output to p.out,
+// and stores the running *exec.Cmd in the run field.
+func (p *process) start(body string, opt *Options) error {
+	// We "go build" and then exec the binary so that the
+	// resultant *exec.Cmd is a handle to the user's program
+	// (rather than the go tool process).
+	// This makes Kill work.
+
+	path, err := ioutil.TempDir(buildDir, "present-")
+	if err != nil {
+		return err
+	}
+	p.path = path // to be removed by p.end
+
+	out := "prog"
+	if runtime.GOOS == "windows" {
+		out = "prog.exe"
+	}
+	bin := filepath.Join(path, out)
+
+	// write body to x.go
+	src := filepath.Join(path, "prog.go")
+	if err := ioutil.WriteFile(src, []byte(body), 0666); err != nil {
+		return err
+	}
+
+	// build x.go, creating x
+	args := []string{"go", "build", "-tags", "OMIT"}
+	if opt != nil && opt.Race {
+		p.out <- &Message{
+			Kind: "stderr",
+			Body: "Running with race detector.\n",
+		}
+		args = append(args, "-race")
+	}
+	args = append(args, "-o", bin, src)
+	cmd := p.cmd(path, args...)
+	start := time.Now()
+	err = cmd.Run()
+	stats.build(time.Since(start))
+	if err != nil {
+		stats.failure(failBuild)
+		return err
+	}
+
+	// run x
+	cmd = p.cmd("", bin)
+	if opt != nil && opt.Race {
+		cmd.Env = append(cmd.Env, "GOMAXPROCS=2")
+	}
+	if err := cmd.Start(); err != nil {
+		stats.failure(failStart)
+		// If we failed to exec, that might be because they built
+		// a non-main package instead of an executable.
+		// Check and report that.
+		if name, err := packageName(body); err == nil && name != "main" {
+			return errors.New(`executable programs must use "package main"`)
+		}
+		return err
+	}
+	p.run = cmd
+	return nil
+}
+
+// exitKind returns the failure kind of a program that exited with err.
+func exitKind(err error) string {
+	if e, ok := err.(*exec.ExitError); ok && e.ExitCode() == -1 {
+		return failKilled
+	}
+	return failExit
+}
+
+// cmd builds an *exec.Cmd that writes its standard output and error to the
+// process' output channel.
+func (p *process) cmd(dir string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(runCtx, args[0], args[1:]...)
+	cmd.Dir = dir
+	cmd.Env = environ()
+	cmd.Stdout = &messageWriter{kind: "stdout", out: p.out}
+	cmd.Stderr = &messageWriter{kind: "stderr", out: p.out}
+	return cmd
+}
+
+func packageName(body string) (string, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), "prog.go",
+		strings.NewReader(body), parser.PackageClauseOnly)
+	if err != nil {
+		return "", err
+	}
+	return f.Name.String(), nil
+}
+
+// messageWriter is an io.Writer that converts all writes to Message sends on
+// the out channel with the specified id and kind.
+type messageWriter struct {
+	kind string
+	out  chan<- *Message
+}
+
+func (w *messageWriter) Write(b []byte) (n int, err error) {
+	w.out <- &Message{Kind: w.kind, Body: safeString(b)}
+	return len(b), nil
+}
+
+// safeString returns b as a valid UTF-8 string.
+func safeString(b []byte) string {
+	if utf8.Valid(b) {
+		return string(b)
+	}
+	var buf bytes.Buffer
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		b = b[size:]
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+type fakeKiller struct {
+	once   sync.Once
+	killed chan struct{}
+}
+
+func (k *fakeKiller) Kill() { k.once.Do(func() { close(k.killed) }) }
+
+func TestLimiter(t *testing.T) {
+	in := make(chan *Message)
+	k := &fakeKiller{killed: make(chan struct{})}
+	out := limiter(in, k)
+	go func() {
+		for i := 0; i < msgLimit+10; i++ {
+			in <- &Message{Kind: "stdout", Body: "x"}
+		}
+		in <- &Message{Kind: "end"}
+	}()
+	n := 0
+	var last *Message
+	for m := range out {
+		n++
+		last = m
+	}
+	if n != msgLimit+1 || last.Kind != "end" {
+		t.Errorf("got %d messages ending with %q, want %d ending with end", n, last.Kind, msgLimit+1)
+	}
+	select {
+	case <-k.killed:
+	case <-time.After(time.Second):
+		t.Error("process not killed after msgLimit messages")
+	}
+}
+
+func TestBuffer(t *testing.T) {
+	in := make(chan *Message)
+	out := buffer(in)
+	go func() {
+		in <- &Message{Kind: "stdout", Body: "a"}
+		in <- &Message{Kind: "stdout", Body: "b"}
+		in <- &Message{Kind: "stderr", Body: "c"}
+		in <- &Message{Kind: "end", Body: "exit status 1"}
+	}()
+	var got []string
+	for m := range out {
+		got = append(got, m.Kind+":"+m.Body)
+	}
+	want := "stdout:ab stderr:c end:exit status 1"
+	if strings.Join(got, " ") != want {
+		t.Errorf("got %q, want %q", strings.Join(got, " "), want)
+	}
+}
+
+func TestSafeString(t *testing.T) {
+	for in, want := range map[string]string{
+		"hello":     "hello",
+		"héllo":     "héllo",
+		"h\xffllo":  "h�llo",
+		"\xe4\xb8":  "��",
+		"世界\xc0end": "世界�end",
+	} {
+		if got := safeString([]byte(in)); got != want {
+			t.Errorf("safeString(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestPackageName(t *testing.T) {
+	for body, want := range map[string]string{
+		"package main\n\nfunc main() {}": "main",
+		"// Comment.\npackage lib\n":     "lib",
+		"func main() {}":                 "",
+	} {
+		got, err := packageName(body)
+		if got != want || (err != nil) != (want == "") {
+			t.Errorf("packageName(%q) = %q, %v; want %q", body, got, err, want)
+		}
+	}
+}
+
+func TestExitKind(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	if got := exitKind(exec.Command("sh", "-c", "exit 2").Run()); got != failExit {
+		t.Errorf("exit 2: got %q, want %q", got, failExit)
+	}
+	if got := exitKind(exec.Command("sh", "-c", "kill -9 $$").Run()); got != failKilled {
+		t.Errorf("killed: got %q, want %q", got, failKilled)
+	}
+}
+
+// collect returns the messages of process id sent on dest up to its end.
+func collect(t *testing.T, dest <-chan *Message, id string) (stdout, end string) {
+	for {
+		select {
+		case m := <-dest:
+			if m.Id != id {
+				t.Fatalf("got message for %q, want %q", m.Id, id)
+			}
+			switch m.Kind {
+			case "stdout":
+				stdout += m.Body
+			case "end":
+				return stdout, m.Body
+			}
+		case <-time.After(time.Minute):
+			t.Fatal("no end message")
+		}
+	}
+}
+
+func TestStartProcess(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a program")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("no go command")
+	}
+	buildDir = t.TempDir()
+	defer func() { buildDir = "" }()
+
+	dest := make(chan *Message)
+	startProcess("1", "package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Println(\"hi\") }\n", dest, nil)
+	if stdout, end := collect(t, dest, "1"); stdout != "hi\n" || end != "" {
+		t.Errorf("got output %q and end %q, want %q and no error", stdout, end, "hi\n")
+	}
+	startProcess("2", "package lib\n", dest, nil)
+	if _, end := collect(t, dest, "2"); end == "" {
+		t.Error("non-main package: got no error")
+	}
+	runs.Wait()
+}
+
+func TestStartProcessDraining(t *testing.T) {
+	defer func() { draining = false }()
+	stopRuns()
+	dest := make(chan *Message, 1)
+	if p := startProcess("1", "package main\n\nfunc main() {}\n", dest, nil); p != nil {
+		t.Error("got a process while shutting down")
+	}
+	if m := <-dest; m.Kind != "end" || m.Body != "the tour is shutting down" {
+		t.Errorf("got %s message %q, want the shutdown end message", m.Kind, m.Body)
+	}
+	if startRun() {
+		t.Error("startRun after stopRuns: got true")
+	}
+}
+
+func TestSocketKillWhileWriting(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a program")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("no go command")
+	}
+	buildDir = t.TempDir()
+	defer func() { buildDir = "" }()
+
+	var ws websocket.Server
+	srv := httptest.NewServer(&ws)
+	defer srv.Close()
+	origin, _ := url.Parse(srv.URL)
+	ws = newSocketHandler(origin)
+
+	c, err := websocket.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), "", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog := "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfor {\n\t\tfmt.Println(\"hi\")\n\t}\n}\n"
+	if err := json.NewEncoder(c).Encode(&Message{Id: "1", Kind: "run", Body: prog}); err != nil {
+		t.Fatal(err)
+	}
+	var m Message
+	if err := json.NewDecoder(c).Decode(&m); err != nil || m.Kind != "stdout" {
+		t.Fatalf("got %s message %q, %v; want output", m.Kind, m.Body, err)
+	}
+	// Hang up while the program is still writing: the handler can no
+	// longer send its output, and has to kill it all the same.
+	c.Close()
+
+	done := make(chan struct{})
+	go func() {
+		runs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatal("run not killed after the client went away")
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Tobecoder/go/tools/present"
+)
+
+// textRenderer renders present documents as plain text for terminals.
+type textRenderer struct {
+	w     io.Writer
+	width int // maximum line width in columns
+
+	quizzes int // quizzes written on the current page, for numbering them
+}
+
+// section writes the title and elements of s.
+func (t *textRenderer) section(s present.Section) {
+	title := s.FormattedNumber() + " " + s.Title
+	fmt.Fprintf(t.w, "%s\n%s\n\n", title, strings.Repeat("=", displayWidth(title)))
+	for _, e := range s.Elem {
+		t.elem(e)
+	}
+}
+
+func (t *textRenderer) elem(e present.Elem) {
+	switch e := e.(type) {
+	case present.Section:
+		t.section(e)
+	case present.Text:
+		if e.Pre {
+			for _, l := range strings.Split(strings.Join(e.Lines, "\n"), "\n") {
+				fmt.Fprintf(t.w, "    %s\n", l)
+			}
+		} else {
+			t.wrap(plainText(joinLines(e.Lines)), "", "")
+		}
+	case present.List:
+		t.list(e, "  ")
+	case present.Code:
+		header := "code"
+		if e.Play {
+			header = "play"
+		}
+		fmt.Fprintf(t.w, "--- %s: %s ---\n", header, e.FileName)
+		t.w.Write(bytes.Replace(e.Raw, []byte("\t"), []byte("    "), -1))
+		fmt.Fprintf(t.w, "---\n")
+	case present.Table:
+		t.table(e)
+	case present.Math:
+		fmt.Fprintf(t.w, "    %s\n", e.TeX)
+	case present.Diagram:
+		for _, l := range e.Body {
+			fmt.Fprintf(t.w, "    %s\n", l)
+		}
+	case present.Image:
+		fmt.Fprintf(t.w, "[image: %s]\n", e.URL)
+	case present.Link:
+		fmt.Fprintf(t.w, "%s <%s>\n", e.Label, e.URL)
+	case present.Iframe:
+		fmt.Fprintf(t.w, "[page: %s]\n", e.URL)
+	case present.Video:
+		fmt.Fprintf(t.w, "[video: %s]\n", e.URL)
+	case present.Caption:
+		t.wrap(plainText(e.Text), "", "")
+	case present.Quiz:
+		t.wrap(plainText(e.Question), "", "")
+		for _, c := range e.Choices {
+			t.wrap(plainText(c.Text), "  "+c.Key+") ", "     ")
+		}
+		if t.quizzes++; t.quizzes == 1 {
+			fmt.Fprintln(t.w, "(answer KEY to answer)")
+		} else {
+			fmt.Fprintf(t.w, "(answer %d KEY to answer)\n", t.quizzes)
+		}
+	case present.Comment, present.Include:
+		return
+	default:
+		fmt.Fprintf(t.w, "[%s]\n", e.TemplateName())
+	}
+	fmt.Fprintln(t.w)
+}
+
+// list writes the items of l, and the lists nested in them, indented
+// by indent.
+func (t *textRenderer) list(l present.List, indent string) {
+	for i, it := range l.Items {
+		marker := "- "
+		if l.Ordered {
+			marker = fmt.Sprintf("%d. ", l.Start+i)
+		}
+		rest := indent + strings.Repeat(" ", len(marker))
+		t.wrap(plainText(it.Text), indent+marker, rest)
+		if it.List != nil {
+			t.list(*it.List, rest)
+		}
+	}
+}
+
+// table writes e with its columns padded to line up. Wide characters
+// take two columns, so the padding is worked out from display widths.
+func (t *textRenderer) table(e present.Table) {
+	rows := e.Rows
+	if e.Header != nil {
+		rows = append([][]string{e.Header}, rows...)
+	}
+	var widths []int
+	cells := make([][]string, len(rows))
+	for i, r := range rows {
+		for j, c := range r {
+			c = plainText(c)
+			cells[i] = append(cells[i], c)
+			if j == len(widths) {
+				widths = append(widths, 0)
+			}
+			if w := displayWidth(c); w > widths[j] {
+				widths[j] = w
+			}
+		}
+	}
+	for i, r := range cells {
+		var line strings.Builder
+		for j, c := range r {
+			pad := widths[j] - displayWidth(c)
+			left := 0
+			switch e.AlignOf(j) {
+			case "right":
+				left = pad
+			case "center":
+				left = pad / 2
+			}
+			if j > 0 {
+				line.WriteString("  ")
+			}
+			line.WriteString(strings.Repeat(" ", left) + c + strings.Repeat(" ", pad-left))
+		}
+		fmt.Fprintln(t.w, strings.TrimRight(line.String(), " "))
+		if i == 0 && e.Header != nil {
+			rule := make([]string, len(widths))
+			for j, w := range widths {
+				rule[j] = strings.Repeat("-", w)
+			}
+			fmt.Fprintln(t.w, strings.Join(rule, "  "))
+		}
+	}
+}
+
+// wrap writes text broken into lines no wider than t.width. The first line
+// starts with first and the following ones with rest.
+func (t *textRenderer) wrap(text, first, rest string) {
+	line, prefix := first, first
+	n := displayWidth(line)
+	for _, word := range splitWords(text) {
+		w := displayWidth(word)
+		if n+w > t.width && line != prefix {
+			fmt.Fprintln(t.w, strings.TrimRight(line, " "))
+			line, prefix = rest, rest
+			n = displayWidth(line)
+			if word == " " {
+				continue
+			}
+		}
+		line += word
+		n += w
+	}
+	if line != prefix {
+		fmt.Fprintln(t.w, strings.TrimRight(line, " "))
+	}
+}
+
+// splitWords splits s into the units lines may be broken between: runs of
+// non-space characters, single spaces, and single wide characters, which
+// are written without spaces between them.
+func splitWords(s string) []string {
+	var words []string
+	start := -1
+	for i, r := range s {
+		switch {
+		case unicode.IsSpace(r), isWide(r):
+			if start >= 0 {
+				words = append(words, s[start:i])
+				start = -1
+			}
+			if unicode.IsSpace(r) {
+				words = append(words, " ")
+			} else {
+				words = append(words, string(r))
+			}
+		case start < 0:
+			start = i
+		}
+	}
+	if start >= 0 {
+		words = append(words, s[start:])
+	}
+	return words
+}
+
+var inlineLinkRE = regexp.MustCompile(`\[\[([^\]]+)\](?:\[([^\]]+)\])?\]`)
+
+// plainText removes the font indicators of present markup and replaces
+// its inline links with their labels, followed by the URL when it leads
+// somewhere outside the page.
+func plainText(s string) string {
+	return inlineLinkRE.ReplaceAllStringFunc(present.Unstyle(s), func(m string) string {
+		sub := inlineLinkRE.FindStringSubmatch(m)
+		url, label := sub[1], sub[2]
+		switch {
+		case label == "":
+			return url
+		case strings.HasPrefix(url, "javascript:"):
+			return label
+		}
+		return label + " <" + url + ">"
+	})
+}
+
+// joinLines joins the lines of a paragraph with spaces, except where a
+// line break falls between two wide characters: Chinese and Japanese
+// don't put spaces between words, so there the break is just dropped.
+func joinLines(lines []string) string {
+	var b strings.Builder
+	for i, l := range lines {
+		if i > 0 {
+			prev, _ := utf8.DecodeLastRuneInString(lines[i-1])
+			next, _ := utf8.DecodeRuneInString(l)
+			if !isWide(prev) || !isWide(next) {
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString(l)
+	}
+	return b.String()
+}
+
+// displayWidth returns the number of terminal columns s occupies.
+func displayWidth(s string) int {
+	n := 0
+	for _, r := range s {
+		switch {
+		case isWide(r):
+			n += 2
+		case unicode.Is(unicode.Mn, r):
+			// combining marks take no room
+		default:
+			n++
+		}
+	}
+	return n
+}
+
+// isWide reports whether r is an East Asian wide or full-width character.
+func isWide(r rune) bool {
+	if r < 0x1100 || r == utf8.RuneError {
+		return false
+	}
+	return r <= 0x115F || // Hangul Jamo
+		r >= 0x2E80 && r <= 0xA4CF && r != 0x303F || // CJK ... Yi
+		r >= 0xAC00 && r <= 0xD7A3 || // Hangul Syllables
+		r >= 0xF900 && r <= 0xFAFF || // CJK Compatibility Ideographs
+		r >= 0xFE30 && r <= 0xFE4F || // CJK Compatibility Forms
+		r >= 0xFF00 && r <= 0xFF60 || // Fullwidth Forms
+		r >= 0xFFE0 && r <= 0xFFE6 ||
+		r >= 0x20000 && r <= 0x3FFFD
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestIsWide(t *testing.T) {
+	for _, tt := range []struct {
+		r    rune
+		want bool
+	}{
+		{'a', false},
+		{'é', false},
+		{'́', false}, // combining acute accent
+		{'�', false}, // replacement character
+		{'ᄀ', true},  // Hangul Jamo
+		{'中', true},
+		{'。', true},
+		{'〿', false}, // U+303F, half-width ideographic space
+		{'한', true},
+		{'！', true},  // fullwidth exclamation mark
+		{'ｱ', false}, // halfwidth katakana
+		{'￥', true},
+		{'𠀀', true}, // CJK Extension B
+		{'😀', false},
+	} {
+		if got := isWide(tt.r); got != tt.want {
+			t.Errorf("isWide(%q) = %v, want %v", tt.r, got, tt.want)
+		}
+	}
+}
+
+func TestDisplayWidth(t *testing.T) {
+	for _, tt := range []struct {
+		s    string
+		want int
+	}{
+		{"", 0},
+		{"hello", 5},
+		{"你好", 4},
+		{"Go 语言", 7},
+		{"é", 1}, // e and a combining accent
+		{"ｱｲ", 2},
+		{"\xff", 1},
+	} {
+		if got := displayWidth(tt.s); got != tt.want {
+			t.Errorf("displayWidth(%q) = %d, want %d", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	for _, tt := range []struct {
+		in, want string
+	}{
+		{"no links", "no links"},
+		{"see [[https://go.dev/]]", "see https://go.dev/"},
+		{"the [[https://go.dev/doc/][docs]].", "the docs <https://go.dev/doc/>."},
+		{"[[javascript:highlight('x')][x]] here", "x here"},
+		{"[[/a][A]] and [[/b][B]]", "A </a> and B </b>"},
+		{"[not a link]", "[not a link]"},
+		{"*bold* and _in_italic_, `code()`.", "bold and in italic, code()."},
+		{"a_b and 2*3*4", "a_b and 2*3*4"},
+		{"*[[/a][A]]*", "*A </a>*"},
+		{"使用 `fmt.Println` 打印", "使用 fmt.Println 打印"},
+	} {
+		if got := plainText(tt.in); got != tt.want {
+			t.Errorf("plainText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestJoinLines(t *testing.T) {
+	for _, tt := range []struct {
+		lines []string
+		want  string
+	}{
+		{nil, ""},
+		{[]string{"one", "two"}, "one two"},
+		{[]string{"Go 是一门", "编程语言。"}, "Go 是一门编程语言。"},
+		{[]string{"变量的", "zero value"}, "变量的 zero value"},
+		{[]string{"the Go", "语言"}, "the Go 语言"},
+	} {
+		if got := joinLines(tt.lines); got != tt.want {
+			t.Errorf("joinLines(%q) = %q, want %q", tt.lines, got, tt.want)
+		}
+	}
+}
+
+func TestWrap(t *testing.T) {
+	for _, tt := range []struct {
+		text        string
+		width       int
+		first, rest string
+		want        string
+	}{
+		{"", 10, "", "", ""},
+		{"short", 10, "", "", "short\n"},
+		{"the quick brown fox", 10, "", "", "the quick\nbrown fox\n"},
+		{"the quick brown fox", 10, "- ", "  ", "- the\n  quick\n  brown\n  fox\n"},
+		{"a verylongwordindeed b", 8, "", "", "a\nverylongwordindeed\nb\n"},
+		{"一二三四五六", 8, "", "", "一二三四\n五六\n"},
+		{"Go 是一门编程语言", 10, "", "", "Go 是一门\n编程语言\n"},
+		{"trailing space  ", 20, "", "", "trailing space\n"},
+	} {
+		var b bytes.Buffer
+		(&textRenderer{w: &b, width: tt.width}).wrap(tt.text, tt.first, tt.rest)
+		if got := b.String(); got != tt.want {
+			t.Errorf("wrap(%q, %d, %q, %q) = %q, want %q", tt.text, tt.width, tt.first, tt.rest, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSelfSignedCert(t *testing.T) {
+	for _, tt := range []struct {
+		host string
+		dns  int
+		ips  int
+	}{
+		{"localhost", 1, 0},
+		{"127.0.0.1", 0, 1},
+		{"::1", 0, 1},
+		{"", 0, 0},
+	} {
+		cert, err := selfSignedCert(tt.host)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.host, err)
+		}
+		c, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatalf("%q: %v", tt.host, err)
+		}
+		if len(c.DNSNames) != tt.dns || len(c.IPAddresses) != tt.ips {
+			t.Errorf("%q: got DNS names %q and IP addresses %v, want %d and %d", tt.host, c.DNSNames, c.IPAddresses, tt.dns, tt.ips)
+		}
+		if tt.host != "" {
+			if err := c.VerifyHostname(tt.host); err != nil {
+				t.Errorf("%q: %v", tt.host, err)
+			}
+		}
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	conf, err := tlsConfig("", "", false, "localhost")
+	if conf != nil || err != nil {
+		t.Errorf("no TLS flags: got %v, %v; want nil, nil", conf, err)
+	}
+	if _, err := tlsConfig("cert.pem", "", false, "localhost"); err == nil {
+		t.Error("-tls-cert without -tls-key: got no error")
+	}
+	if _, err := tlsConfig("missing.pem", "missing.key", false, "localhost"); err == nil {
+		t.Error("missing key pair: got no error")
+	}
+	if conf, err := tlsConfig("", "", true, ""); err != nil || conf == nil || len(conf.Certificates) != 1 {
+		t.Errorf("-tls-self-signed: got %v, %v; want one certificate", conf, err)
+	}
+
+	// A key pair written out loads as the given certificate.
+	cert, err := selfSignedCert("localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	conf, err = tlsConfig(certFile, keyFile, false, "localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conf.Certificates) != 1 || string(conf.Certificates[0].Certificate[0]) != string(cert.Certificate[0]) {
+		t.Error("-tls-cert and -tls-key: didn't load the given certificate")
+	}
+}
@@ -3,6 +3,10 @@ package main
 import (
 	"bytes"
 	"compress/gzip"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"html/template"
 	"io"
@@ -12,39 +16,52 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/Go-zh/tools/godoc/static"
 	"github.com/Tobecoder/go/tools/present"
-	"encoding/json"
-	"crypto/sha1"
-	"encoding/base64"
 )
 
 // 定义变量
 var (
-	uiContent      []byte
-	Lessons        = make(map[string][]byte)
+	uiContent      map[string][]byte                    // rendered UI by language
+	Lessons        = make(map[string]map[string][]byte) // encoded lessons by language and name
+	Graphs         = make(map[string][]byte)            // encoded lesson graph by language
 	lessonNotFound = fmt.Errorf("lesson not found")
 )
 
 // initTour 初始化课程相关信息，主要是渲染模板
 func initTour(root, transport string) error {
-	// 渲染前保证playground可用
-	present.PlayEnabled = true
-
-	// 安装模版 ---- present.Template()为什么必须用这个
-	action := filepath.Join(root, "template", "action.tmpl")
-	tmpl, err := present.Template().ParseFiles(action)
+	//初始化课程
+	tours, err := parseTour(root)
 	if err != nil {
-		return fmt.Errorf("parse %v", err)
+		return err
 	}
-
-	//初始化课程
-	contentPath := filepath.Join(root, "content")
-	if err = initLessons(tmpl, contentPath); err != nil {
-		return fmt.Errorf("init lessons %v", err)
+	// 课程顺序以源语言的课程为准
+	graph, err := lessonGraph(tours[*sourceLang])
+	if err != nil {
+		return err
+	}
+	for lang, lessons := range tours {
+		merged := mergeLessons(lessons, tours[*sourceLang])
+		Lessons[lang] = make(map[string][]byte)
+		for name, l := range merged {
+			b, err := encodeLesson(l)
+			if err != nil {
+				return fmt.Errorf("%s/%s: %v", lang, name, err)
+			}
+			Lessons[lang][name] = b
+		}
+		nodes := make([]lessonNode, len(graph))
+		for i, n := range graph {
+			nodes[i] = *n
+			nodes[i].Title = merged[n.Name].Title
+		}
+		if Graphs[lang], err = json.Marshal(nodes); err != nil {
+			return fmt.Errorf("encode lesson graph: %v", err)
+		}
 	}
 
 	// 初始化UI
@@ -53,64 +70,161 @@ func initTour(root, transport string) error {
 	if err != nil {
 		return fmt.Errorf("parse templates: %v", err)
 	}
-	buf := new(bytes.Buffer)
 
-	data := struct {
-		Transport  template.JS
-		SocketAddr string
-	}{template.JS(transport), socketAddr()}
+	uiContent = make(map[string][]byte)
+	for lang := range tours {
+		translation, err := uiTranslation(root, lang)
+		if err != nil {
+			return err
+		}
+		data := struct {
+			Transport   template.JS
+			SocketAddr  string
+			Lang        string
+			Translation template.JS
+		}{template.JS(transport), socketAddr(), lang, template.JS(translation)}
+
+		buf := new(bytes.Buffer)
+		if err = indexTmpl.Execute(buf, data); err != nil {
+			return fmt.Errorf("render UI: %v", err)
+		}
+		uiContent[lang] = buf.Bytes()
+	}
+	return initScript(root)
+}
+
+// parseTour parses the lessons of every language found under root/content.
+// The articles directly inside content are in the source language; each
+// subdirectory holding lessons is a translation named after its
+// language tag, such as content/en.
+func parseTour(root string) (map[string]map[string]*Lesson, error) {
+	// 渲染前保证playground可用
+	present.PlayEnabled = true
 
-	if err = indexTmpl.Execute(buf, data); err != nil {
-		return fmt.Errorf("render UI: %v", err)
+	// 安装模版 ---- present.Template()为什么必须用这个
+	action := filepath.Join(root, "template", "action.tmpl")
+	tmpl, err := present.Template().ParseFiles(action)
+	if err != nil {
+		return nil, fmt.Errorf("parse %v", err)
 	}
 
-	uiContent = buf.Bytes()
-	return initScript(root)
+	content := filepath.Join(root, "content")
+	tours := make(map[string]map[string]*Lesson)
+	if tours[*sourceLang], err = initLessons(tmpl, content, *sourceLang); err != nil {
+		return nil, fmt.Errorf("init lessons %v", err)
+	}
+	dirs, err := ioutil.ReadDir(content)
+	if err != nil {
+		return nil, err
+	}
+	for _, fi := range dirs {
+		if !fi.IsDir() {
+			continue
+		}
+		lessons, err := initLessons(tmpl, filepath.Join(content, fi.Name()), fi.Name())
+		if err != nil {
+			return nil, fmt.Errorf("init %s lessons %v", fi.Name(), err)
+		}
+		switch {
+		case len(lessons) == 0:
+		case fi.Name() != *sourceLang:
+			tours[fi.Name()] = lessons
+		case len(tours[*sourceLang]) == 0:
+			// The source language lives in its own directory too.
+			tours[*sourceLang] = lessons
+		}
+	}
+	return tours, nil
 }
 
-func initLessons(tmpl *template.Template, content string) error {
+// initLessons parses every lesson file in the content directory, which
+// holds the lessons in lang.
+func initLessons(tmpl *template.Template, content, lang string) (map[string]*Lesson, error) {
 	f, err := os.Open(content)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer f.Close()
 	files, err := f.Readdirnames(0)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	lessons := make(map[string]*Lesson)
 	for _, file := range files {
-		if !strings.HasSuffix(file, ".article") {
+		name := lessonName(file)
+		if name == "" {
 			continue
 		}
-		article, err := parseLessons(tmpl, filepath.Join(content, file))
+		if _, ok := lessons[name]; ok {
+			return nil, fmt.Errorf("lesson %q is defined twice", name)
+		}
+		lesson, err := parseLesson(tmpl, lang, filepath.Join(content, file))
 		if err != nil {
-			return fmt.Errorf("parsing %v: %v", file, err)
+			return nil, fmt.Errorf("parsing %v: %v", file, err)
 		}
-		name := strings.TrimSuffix(file, ".article")
-		Lessons[name] = article
+		lessons[name] = lesson
 	}
-	return nil
+	if !*showDrafts {
+		hideDrafts(lessons)
+	}
+	return lessons, nil
+}
+
+// lessonName returns the name of the lesson in file, or "" if file is not
+// a lesson. Lessons are written in present markup (.article) or in
+// Markdown (.md).
+func lessonName(file string) string {
+	switch ext := filepath.Ext(file); ext {
+	case ".article", ".md":
+		return strings.TrimSuffix(filepath.Base(file), ext)
+	}
+	return ""
+}
+
+// lessonFiles returns the lesson files in dir.
+func lessonFiles(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		return nil, err
+	}
+	var lessons []string
+	for _, f := range files {
+		if lessonName(f) != "" {
+			lessons = append(lessons, f)
+		}
+	}
+	return lessons, nil
 }
 
+// Lesson defines the JSON form of a tour lesson.
 type Lesson struct {
 	Title       string
 	Description string
 	Pages       []Page
+	Order       int      `json:",omitempty"`
+	Level       string   `json:",omitempty"`
+	Requires    []string `json:",omitempty"`
+	Draft       bool     `json:",omitempty"`
 }
 
+// Page defines the JSON form of a tour lesson page.
 type Page struct {
 	Title   string
 	Content string
 	Files   []File
 }
 
+// File defines the JSON form of a code file in a page.
 type File struct {
 	Name    string
 	Content string
 	Hash    string
 }
 
-func parseLessons(tmpl *template.Template, path string) ([]byte, error) {
+// parseLesson parses and returns a lesson content given its path, its
+// language and the template to render it.
+func parseLesson(tmpl *template.Template, lang, path string) (*Lesson, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
@@ -120,17 +234,14 @@ func parseLessons(tmpl *template.Template, path string) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	lesson := Lesson{
-		doc.Title,
-		doc.Subtitle,
-		make([]Page, len(doc.Sections)),
-	}
-
-	for i, v := range doc.Sections {
-		p := &lesson.Pages[i]
-		p.Title = v.Title
-		codes := findPlayCode(sec)
-		p.Files = make
+	lesson := &Lesson{
+		Title:       doc.Title,
+		Description: doc.Subtitle,
+		Pages:       make([]Page, len(doc.Sections)),
+		Order:       doc.Order,
+		Level:       doc.Level,
+		Requires:    doc.Requires,
+		Draft:       doc.Draft,
 	}
 
 	for i, sec := range doc.Sections {
@@ -141,6 +252,9 @@ func parseLessons(tmpl *template.Template, path string) ([]byte, error) {
 		}
 		p.Title = sec.Title
 		p.Content = w.String()
+		for _, q := range findQuizzes(sec) {
+			quizzes[lang+"/"+lessonName(path)+"/"+q.ID] = q
+		}
 		codes := findPlayCode(sec)
 		p.Files = make([]File, len(codes))
 		for i, c := range codes {
@@ -151,133 +265,66 @@ func parseLessons(tmpl *template.Template, path string) ([]byte, error) {
 			f.Hash = base64.StdEncoding.EncodeToString(hash[:])
 		}
 	}
+	return lesson, nil
+}
 
+// encodeLesson returns the JSON form of a lesson.
+func encodeLesson(l *Lesson) ([]byte, error) {
 	w := new(bytes.Buffer)
-	err = json.NewEncoder(w).Encode(lesson)
-	if err != nil {
+	if err := json.NewEncoder(w).Encode(l); err != nil {
 		return nil, fmt.Errorf("encode lesson: %v", err)
 	}
 	return w.Bytes(), nil
 }
 
-//// initLessons 初始化课程导航
-//func initLessons(tmpl *template.Template, content string) error {
-//	dir, err := os.Open(content)
-//	if err != nil {
-//		return err
-//	}
-//	files, err := dir.Readdirnames(0)
-//	if err != nil {
-//		return err
-//	}
-
-//	for _, f := range files {
-//		if filepath.Ext(f) != ".article" {
-//			continue
-//		}
-//		content, err := parseLesson(tmpl, filepath.Join(content, f))
-//		if err != nil {
-//			return fmt.Errorf("parsing %v: %v", f, err)
-//		}
-//		name := strings.TrimSuffix(f, ".article")
-//		Lessons[name] = content
-//	}
-//	return nil
-//}
-
-//// File defines the JSON form of a code file in a page.
-//type File struct {
-//	Name    string
-//	Content string
-//	Hash    string
-//}
-
-//// Page defines the JSON form of a tour lesson page.
-//type Page struct {
-//	Title   string
-//	Content string
-//	Files   []File
-//}
-
-//// Lesson defines the JSON form of a tour lesson.
-//type Lesson struct {
-//	Title       string
-//	Description string
-//	Pages       []Page
-//}
-
-//// parseLesson parses and returns a lesson content given its name and
-//// the template to render it.
-//func parseLesson(tmpl *template.Template, path string) ([]byte, error) {
-//	file, err := os.Open(path)
-//	if err != nil {
-//		return nil, err
-//	}
-//	defer file.Close()
-//	doc, err := present.Parse(file, path, 0)
-//	if err != nil {
-//		return nil, err
-//	}
-
-//	lesson := Lesson{
-//		doc.Title,
-//		doc.Subtitle,
-//		make([]Page, len(doc.Sections)),
-//	}
-
-//	for i, sec := range doc.Sections {
-//		p := &lesson.Pages[i]
-//		w := new(bytes.Buffer)
-//		if err := sec.Render(w, tmpl); err != nil {
-//			return nil, fmt.Errorf("render section: %v", err)
-//		}
-//		p.Title = sec.Title
-//		p.Content = w.String()
-//		codes := findPlayCode(sec)
-//		p.Files = make([]File, len(codes))
-//		for i, c := range codes {
-//			f := &p.Files[i]
-//			f.Name = c.FileName
-//			f.Content = string(c.Raw)
-//			hash := sha1.Sum(c.Raw)
-//			f.Hash = base64.StdEncoding.EncodeToString(hash[:])
-//		}
-//	}
-
-//	w := new(bytes.Buffer)
-//	if err := json.NewEncoder(w).Encode(lesson); err != nil {
-//		return nil, fmt.Errorf("encode lesson: %v", err)
-//	}
-//	return w.Bytes(), nil
-//}
-
-//// findPlayCode returns a slide with all the Code elements in the given
-//// Elem with Play set to true.
-//func findPlayCode(e present.Elem) []*present.Code {
-//	var r []*present.Code
-//	switch v := e.(type) {
-//	case present.Code:
-//		if v.Play {
-//			r = append(r, &v)
-//		}
-//	case present.Section:
-//		for _, s := range v.Elem {
-//			r = append(r, findPlayCode(s)...)
-//		}
-//	}
-//	return r
-//}
+// mergeLessons fills the gaps of a translation with the source lessons:
+// lessons that were not translated are taken as a whole, and a translated
+// lesson that stops early is completed with the remaining source pages.
+func mergeLessons(tr, src map[string]*Lesson) map[string]*Lesson {
+	merged := make(map[string]*Lesson)
+	for name, l := range src {
+		merged[name] = l
+	}
+	for name, l := range tr {
+		s, ok := src[name]
+		if ok && len(l.Pages) < len(s.Pages) {
+			c := *l
+			c.Pages = append(append([]Page{}, l.Pages...), s.Pages[len(l.Pages):]...)
+			l = &c
+		}
+		merged[name] = l
+	}
+	return merged
+}
+
+// findPlayCode returns a slide with all the Code elements in the given
+// Elem with Play set to true.
+func findPlayCode(e present.Elem) []*present.Code {
+	var r []*present.Code
+	switch v := e.(type) {
+	case present.Code:
+		if v.Play {
+			r = append(r, &v)
+		}
+	case present.Section:
+		for _, s := range v.Elem {
+			r = append(r, findPlayCode(s)...)
+		}
+	}
+	return r
+}
 
 // writeLesson writes the tour content to the provided Writer.
 // 流程需要详细了解
-func writeLesson(name string, w io.Writer) error {
+func writeLesson(name, lang string, w io.Writer) error {
 	if uiContent == nil {
 		panic("writeLesson called before successful initTour")
 	}
+	lessons := Lessons[lang]
 	if len(name) == 0 {
-		return writeAllLessons(w)
+		return writeAllLessons(lessons, w)
 	}
-	l, ok := Lessons[name]
+	l, ok := lessons[name]
 	if !ok {
 		return lessonNotFound
 	}
@@ -285,21 +332,33 @@ func writeLesson(name string, w io.Writer) error {
 	return err
 }
 
-func writeAllLessons(w io.Writer) error {
+// writeGraph writes the lesson graph of the given language to w.
+func writeGraph(lang string, w io.Writer) error {
+	if uiContent == nil {
+		panic("writeGraph called before successful initTour")
+	}
+	_, err := w.Write(Graphs[lang])
+	return err
+}
+
+func writeAllLessons(lessons map[string][]byte, w io.Writer) error {
 	if _, err := fmt.Fprint(w, "{"); err != nil {
 		return err
 	}
-	length := len(Lessons)
-	for k, v := range Lessons {
-		if _, err := fmt.Fprintf(w, "%q:%s", k, v); err != nil {
-			return err
-		}
-		length--
-		if length > 0 {
+	names := make([]string, 0, len(lessons))
+	for k := range lessons {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for i, k := range names {
+		if i > 0 {
 			if _, err := fmt.Fprint(w, ","); err != nil {
 				return err
 			}
 		}
+		if _, err := fmt.Fprintf(w, "%q:%s", k, lessons[k]); err != nil {
+			return err
+		}
 	}
 	_, err := fmt.Fprint(w, "}")
 	return err
@@ -349,8 +408,10 @@ func initScript(root string) error {
 	gz.Write(buf.Bytes())
 	gz.Close()
 
+	// The script doesn't change while the server runs, so browsers may
+	// revalidate it against the startup time.
+	modTime := time.Now()
 	http.HandleFunc("/script.js", func(w http.ResponseWriter, r *http.Request) {
-		modTime := time.Now()
 		// 设置返回头信息
 		w.Header().Set("Content-type", "application/javascript")
 		w.Header().Set("Cache-control", "max-age=604800")
@@ -365,24 +426,32 @@ func initScript(root string) error {
 }
 
 // renderUI 渲染UI内容到终端
-func renderUI(w io.Writer) error {
+func renderUI(lang string, w io.Writer) error {
 	if uiContent == nil {
 		panic("renderUI called before successful initTour")
 	}
-	_, err := w.Write(uiContent)
+	_, err := w.Write(uiContent[lang])
 	return err
 }
 
 // socketAddr 获取socket服务地址
 func socketAddr() string {
+	if useTLS {
+		return "wss://" + httpAddr + SocketPath
+	}
 	return "ws://" + httpAddr + SocketPath
 }
 
 // waitServer 服务器是否准备就绪
 func waitServer(url string) bool {
+	// The self-signed certificate can't be verified; we only want to
+	// know that the server is up.
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}}
 	times := 20
 	for times > 0 {
-		resp, err := http.Get(url)
+		resp, err := client.Get(url)
 		if err == nil {
 			resp.Body.Close()
 			return true
@@ -149,6 +149,44 @@ directive('horizontalSlide', ['editor',
     }
 ]).
 
+// quizzes sends the answers to the quizzes in the page content to the
+// server, shows whether they were right, and marks the ones answered
+// before.
+directive('quizzes', ['$window', 'progress', 'i18n',
+    function(win, progress, i18n) {
+        var show = function(form, choice, correct, answer) {
+            form.find('input[value="' + choice + '"]').prop('checked', true);
+            form.removeClass('right wrong').addClass('answered ' + (correct ? 'right' : 'wrong'));
+            var text = i18n.l(correct ? 'quiz-right' : 'quiz-wrong');
+            if (!correct && answer) text += i18n.l('quiz-answer') + ' ' + answer + ')';
+            form.find('.result').text(text);
+        };
+        return function(scope, elm, attrs) {
+            elm.on('change', '.quiz input[type=radio]', function() {
+                var form = $(this).closest('.quiz');
+                var choice = $(this).val();
+                progress.answer(scope.lessonId, form.data('quiz'), choice).then(function(resp) {
+                    show(form, choice, resp.data.Correct, resp.data.Answer);
+                });
+            });
+            scope.$watch(attrs.ngBindHtmlUnsafe, function() {
+                // Wait for the content to be in the page.
+                win.setTimeout(function() {
+                    var forms = elm.find('.quiz');
+                    if (forms.length === 0) return;
+                    progress.answers().then(function(resp) {
+                        forms.each(function() {
+                            var form = $(this);
+                            var a = resp.data && resp.data[scope.lessonId + '/' + form.data('quiz')];
+                            if (a) show(form, a.Choice, a.Correct, '');
+                        });
+                    });
+                }, 0);
+            });
+        };
+    }
+]).
+
 directive('tableOfContentsButton', ['i18n', function(i18n) {
     var speed = 250;
     return {
@@ -76,6 +76,30 @@ factory('fmt', ['$http',
     }
 ]).
 
+// Quiz answers, recorded by the server for this browser.
+factory('progress', ['$http',
+    function($http) {
+        return {
+            answers: function() {
+                return $http.get('/progress');
+            },
+            answer: function(lesson, quiz, choice) {
+                var params = $.param({
+                    'lesson': lesson,
+                    'quiz': quiz,
+                    'choice': choice
+                });
+                var headers = {
+                    'Content-Type': 'application/x-www-form-urlencoded'
+                };
+                return $http.post('/progress', params, {
+                    headers: headers
+                });
+            }
+        };
+    }
+]).
+
 // Local storage, persistent to page refreshing.
 factory('storage', ['$window',
     function(win) {
@@ -150,37 +174,61 @@ factory('editor', ['$window', 'storage',
 // Table of contents management and navigation
 factory('toc', ['$http', '$q', '$log', 'tableOfContents', 'storage',
     function($http, $q, $log, tableOfContents, storage) {
-        var modules = tableOfContents;
+        var modules = [];
 
         var lessons = {};
 
+        // lesson graph nodes by lesson name, from /lesson-graph.
+        var nodes = {};
+
         var prevLesson = function(id) {
-            var mod = lessons[id].module;
-            var idx = mod.lessons.indexOf(id);
-            if (idx < 0) return '';
-            if (idx > 0) return mod.lessons[idx - 1];
-
-            idx = modules.indexOf(mod);
-            if (idx <= 0) return '';
-            mod = modules[idx - 1];
-            return mod.lessons[mod.lessons.length - 1];
+            return nodes[id] ? nodes[id].Prev : '';
         };
 
         var nextLesson = function(id) {
-            var mod = lessons[id].module;
-            var idx = mod.lessons.indexOf(id);
-            if (idx < 0) return '';
-            if (idx + 1 < mod.lessons.length) return mod.lessons[idx + 1];
-
-            idx = modules.indexOf(mod);
-            if (idx < 0 || modules.length <= idx + 1) return '';
-            mod = modules[idx + 1];
-            return mod.lessons[0];
+            return nodes[id] ? nodes[id].Next : '';
         };
 
-        $http.get('/lesson/').then(
+        // buildModules groups the lessons, in reading order, into the modules
+        // of the table of contents. Lessons no module lists get a module
+        // named after their level.
+        var buildModules = function(graph) {
+            var byLevel = {};
+            var listed = {};
+            var mods = [];
+            for (var m = 0; m < tableOfContents.length; m++) {
+                var mod = angular.extend({}, tableOfContents[m], {lessons: []});
+                for (var l = 0; l < tableOfContents[m].lessons.length; l++) {
+                    listed[tableOfContents[m].lessons[l]] = mod;
+                }
+                mods.push(mod);
+            }
+            for (var i = 0; i < graph.length; i++) {
+                var node = graph[i];
+                var mod = listed[node.Name];
+                if (mod === undefined) {
+                    var level = node.Level || 'more';
+                    if (byLevel[level] === undefined) {
+                        byLevel[level] = {id: level, title: level, description: '', lessons: []};
+                        mods.push(byLevel[level]);
+                    }
+                    mod = byLevel[level];
+                }
+                mod.lessons.push(node.Name);
+            }
+            return mods.filter(function(mod) {
+                return mod.lessons.length > 0;
+            });
+        };
+
+        $q.all([$http.get('/lesson/'), $http.get('/lesson-graph')]).then(
             function(data) {
-                lessons = data.data;
+                lessons = data[0].data;
+                var graph = data[1].data;
+                for (var i = 0; i < graph.length; i++) {
+                    nodes[graph[i].Name] = graph[i];
+                }
+                modules = buildModules(graph);
                 for (var m = 0; m < modules.length; m++) {
                     var module = modules[m];
                     module.lesson = {};
@@ -53,6 +53,9 @@ value('translation', {
     'issue-title': '[简单描述一下要汇报的问题]',
     'issue-message': '请修改上方的标题来简要描述要汇报的问题，并把详细的内容写在这里，如果可能的话请附上源代码',
     'context': '上下文',
+    'quiz-right': '回答正确！',
+    'quiz-wrong': '回答错误。',
+    'quiz-answer': '正确答案是',
 }).
 
 // Config for codemirror plugin